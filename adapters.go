@@ -0,0 +1,315 @@
+package bongo
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+//ContentFile is the file at the project root which generates pages from the
+// data files, see ContentEntry.
+const ContentFile = "_content.yml"
+
+type (
+	//ContentAdapter is implemented by plugins which generate pages without
+	// markdown files. Pages is called with the content of the data files, after
+	// the markdown files are loaded and before the OnPagesLoaded hooks. The
+	// generated pages are in sections, menus and search like the other pages.
+	ContentAdapter interface {
+		Pages(data map[string]interface{}) ([]*VirtualPage, error)
+	}
+
+	//VirtualPage is a page generated by a ContentAdapter.
+	VirtualPage struct {
+		// Path is the markdown file the page would have, relative to the
+		// project root. It sets the url and the language of the page, and its
+		// section when sections are taken from directories. No two pages can
+		// have the same path.
+		Path string
+
+		// Data is the front matter and Content is the markdown body.
+		Data    map[string]interface{}
+		Content []byte
+
+		// ModTime is the newest modification time of the configuration,
+		// content and data files when it is zero, so that it only changes with
+		// them.
+		ModTime time.Time
+	}
+
+	//ContentEntry generates a page for every item of a list in the data files.
+	// The content file is a list of entries, like
+	//
+	//	- data: releases
+	//	  path: releases/{{.version}}.md
+	//	  content: "{{.notes}}"
+	//	  front:
+	//	    title: Release {{.version}}
+	//	    section: releases
+	//
+	// Data is the key of the list, with dots for subdirectories of the data
+	// directory like team.members. The front matter of a page are the fields of
+	// its item and front. Path, content and the strings in front are templates,
+	// executed with the item.
+	ContentEntry struct {
+		Data    string                 `yaml:"data"`
+		Path    string                 `yaml:"path"`
+		Content string                 `yaml:"content"`
+		Front   map[string]interface{} `yaml:"front"`
+	}
+
+	// contentEntries is the ContentAdapter of the content file.
+	contentEntries []*ContentEntry
+)
+
+// loadContentFile returns the entries of the content file at root, which is
+// optional.
+func loadContentFile(root string) (contentEntries, error) {
+	b, err := ioutil.ReadFile(filepath.Join(root, ContentFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var rst contentEntries
+	if err = yaml.Unmarshal(b, &rst); err != nil {
+		return nil, fmt.Errorf("%s: %v", ContentFile, err)
+	}
+	return rst, nil
+}
+
+func (c contentEntries) Pages(data map[string]interface{}) ([]*VirtualPage, error) {
+	var rst []*VirtualPage
+	for i, e := range c {
+		if e == nil {
+			continue
+		}
+		pages, err := e.pages(data)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i+1, err)
+		}
+		rst = append(rst, pages...)
+	}
+	return rst, nil
+}
+
+func (e *ContentEntry) pages(data map[string]interface{}) ([]*VirtualPage, error) {
+	switch {
+	case e.Data == "":
+		return nil, fmt.Errorf("missing data")
+	case e.Path == "":
+		return nil, fmt.Errorf("missing path")
+	}
+	items, err := dataItems(data, e.Data)
+	if err != nil {
+		return nil, err
+	}
+	front, _ := normalize(e.Front).(map[string]interface{})
+	var rst []*VirtualPage
+	for i, item := range items {
+		name, err := executeText(e.Path, item)
+		if err != nil {
+			return nil, fmt.Errorf("%s %d: path: %v", e.Data, i+1, err)
+		}
+		content, err := executeText(e.Content, item)
+		if err != nil {
+			return nil, fmt.Errorf("%s %d: content: %v", e.Data, i+1, err)
+		}
+		v := &VirtualPage{Path: name, Data: make(map[string]interface{}), Content: []byte(content)}
+		for k, val := range item {
+			v.Data[k] = val
+		}
+		for k, val := range front {
+			if v.Data[k], err = expandText(val, item); err != nil {
+				return nil, fmt.Errorf("%s %d: front %s: %v", e.Data, i+1, k, err)
+			}
+		}
+		rst = append(rst, v)
+	}
+	return rst, nil
+}
+
+// dataItems returns the list at the dotted key in data, the items of which are
+// maps.
+func dataItems(data map[string]interface{}, key string) ([]map[string]interface{}, error) {
+	var v interface{} = data
+	for _, k := range strings.Split(key, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("no data %s", key)
+		}
+		if v, ok = m[k]; !ok {
+			return nil, fmt.Errorf("no data %s", key)
+		}
+	}
+	var rst []map[string]interface{}
+	switch list := v.(type) {
+	case []interface{}:
+		for i, item := range list {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s %d is not a map", key, i+1)
+			}
+			rst = append(rst, m)
+		}
+	case []map[string]string:
+		for _, item := range list {
+			m := make(map[string]interface{})
+			for k, val := range item {
+				m[k] = val
+			}
+			rst = append(rst, m)
+		}
+	default:
+		return nil, fmt.Errorf("%s is not a list", key)
+	}
+	return rst, nil
+}
+
+// expandText executes the strings in v as templates with data.
+func expandText(v interface{}, data interface{}) (interface{}, error) {
+	switch x := v.(type) {
+	case string:
+		return executeText(x, data)
+	case map[string]interface{}:
+		m := make(map[string]interface{})
+		for k, val := range x {
+			var err error
+			if m[k], err = expandText(val, data); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case []interface{}:
+		list := make([]interface{}, len(x))
+		for i, val := range x {
+			var err error
+			if list[i], err = expandText(val, data); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	return v, nil
+}
+
+func executeText(text string, data interface{}) (string, error) {
+	tpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err = tpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// page returns the page of v in the project at root, modTime is its
+// modification time when v has none.
+func (v *VirtualPage) page(root string, modTime time.Time) (*Page, error) {
+	file, err := projectFile(root, v.Path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Page{Path: file, Body: v.Content, ModTime: v.ModTime, virtual: true}
+	if p.ModTime.IsZero() {
+		p.ModTime = modTime
+	}
+	if v.Data != nil {
+		p.Data = v.Data
+	} else {
+		p.Data = make(map[string]interface{})
+	}
+	return p, nil
+}
+
+// contentPages returns the pages generated by the content file, and by the
+// plugins which are content adapters, in the project at root. They must not
+// have the path of one of the pages loaded from files, or of each other.
+func (g *App) contentPages(root string, pages PageList) (PageList, error) {
+	entries, err := loadContentFile(root)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	var adapters []ContentAdapter
+	if len(entries) > 0 {
+		names = append(names, ContentFile)
+		adapters = append(adapters, entries)
+	}
+	for _, p := range g.plugins {
+		if a, ok := p.(ContentAdapter); ok {
+			names = append(names, fmt.Sprintf("plugin %s: Pages", p.Name()))
+			adapters = append(adapters, a)
+		}
+	}
+	if len(adapters) == 0 {
+		return nil, nil
+	}
+	data, err := LoadData(filepath.Join(root, DataDir))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, p := range pages {
+		seen[filepath.Clean(p.Path)] = true
+	}
+	modTime := sourceModTime(root)
+	var rst PageList
+	for i, a := range adapters {
+		list, err := a.Pages(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", names[i], err)
+		}
+		for _, v := range list {
+			p, err := v.page(root, modTime)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", names[i], err)
+			}
+			if seen[p.Path] {
+				return nil, fmt.Errorf("%s: page %s already exists", names[i], v.Path)
+			}
+			seen[p.Path] = true
+			rst = append(rst, p)
+		}
+	}
+	return rst, nil
+}
+
+// sourceModTime returns the newest modification time of the configuration
+// file, the content file and the data files of the project at root, or of the
+// root directory when there are none.
+func sourceModTime(root string) time.Time {
+	var rst time.Time
+	newer := func(info os.FileInfo) {
+		if info.ModTime().After(rst) {
+			rst = info.ModTime()
+		}
+	}
+	for _, name := range []string{DefaultConfigFile, ContentFile} {
+		if info, err := os.Stat(filepath.Join(root, name)); err == nil {
+			newer(info)
+		}
+	}
+	filepath.Walk(filepath.Join(root, DataDir), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && HasExt(path, dataExtensions...) {
+			newer(info)
+		}
+		return nil
+	})
+	if rst.IsZero() {
+		if info, err := os.Stat(root); err == nil {
+			rst = info.ModTime()
+		}
+	}
+	return rst
+}
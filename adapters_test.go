@@ -0,0 +1,111 @@
+package bongo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type changelog struct{ PluginBase }
+
+func (changelog) Name() string { return "changelog" }
+
+func (changelog) Pages(data map[string]interface{}) ([]*VirtualPage, error) {
+	var notes []string
+	for _, r := range data["releases"].([]interface{}) {
+		notes = append(notes, r.(map[string]interface{})["version"].(string))
+	}
+	return []*VirtualPage{{
+		Path:    "releases/changelog.md",
+		Data:    map[string]interface{}{"title": "Changelog", "section": "releases"},
+		Content: []byte(strings.Join(notes, ", ")),
+	}}, nil
+}
+
+func TestContentAdapters(t *testing.T) {
+	content := `
+- data: releases
+  path: releases/{{.version}}.md
+  content: "{{.notes}}"
+  front:
+    title: Release {{.version}}
+    section: releases
+`
+	files := map[string]string{
+		DefaultConfigFile:          "theme: plain\n",
+		ContentFile:                content,
+		"_data/releases.yml":       "- version: v1\n  notes: First release\n  tags: [stable]\n- version: v2\n  notes: Second\n",
+		"releases/intro.md":        "---\ntitle: Intro\nsection: releases\n---\nReleases",
+		"_themes/plain/post.html":  `{{.Page.Title}}: {{.Page.HTML}}`,
+		"_themes/plain/index.html": `{{range .CurrentSection}}[{{.Title}}]{{end}}`,
+	}
+	dir := writeProject(t, plainTheme(files))
+	defer os.RemoveAll(dir)
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, name := range []string{DefaultConfigFile, ContentFile, "_data/releases.yml"} {
+		at := modTime.Add(-time.Hour)
+		if name == "_data/releases.yml" {
+			at = modTime
+		}
+		if err := os.Chtimes(filepath.Join(dir, filepath.FromSlash(name)), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	app := New()
+	app.Use(changelog{})
+	err := app.Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, OutputDir)
+	expect := map[string]string{
+		"releases/v1.html":        "Release v1: <p>First release</p>",
+		"releases/v2.html":        "Release v2: <p>Second</p>",
+		"releases/changelog.html": "Changelog: <p>v1, v2</p>",
+	}
+	for name, v := range expect {
+		b, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(b)) != v {
+			t.Errorf("expected %s to be %q got %q", name, v, b)
+		}
+	}
+	b, err := ioutil.ReadFile(filepath.Join(out, "releases", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"[Intro]", "[Release v1]", "[Release v2]", "[Changelog]"} {
+		if !strings.Contains(string(b), title) {
+			t.Errorf("expected %s in the section index got %s", title, b)
+		}
+	}
+	for _, p := range app.pages {
+		if p.virtual && !p.ModTime.Equal(modTime) {
+			t.Errorf("expected %s to have the time of the data file got %v", p.Path, p.ModTime)
+		}
+	}
+	for _, file := range app.Dependencies() {
+		if strings.HasSuffix(file, "v1.md") {
+			t.Errorf("expected no dependency on the generated page %s", file)
+		}
+	}
+
+	errs := map[string]string{
+		"- data: missing\n  path: x.md":               "_content.yml: entry 1: no data missing",
+		"- data: releases\n  path: '{{.name}}.md'":    `_content.yml: entry 1: releases 1: path: template: :1:2: executing "" at <.name>: map has no entry for key "name"`,
+		"- data: releases\n  path: releases/intro.md": "_content.yml: page releases/intro.md already exists",
+	}
+	for v, msg := range errs {
+		if err = ioutil.WriteFile(filepath.Join(dir, ContentFile), []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err = New().Run(dir); err == nil || err.Error() != msg {
+			t.Errorf("expected %q got %v", msg, err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package bongo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gernest/gh"
+	"github.com/tdewolff/minify"
+	"github.com/tdewolff/minify/css"
+	"github.com/tdewolff/minify/html"
+	"github.com/tdewolff/minify/js"
+	"github.com/tdewolff/minify/json"
+	"github.com/tdewolff/minify/svg"
+	"github.com/tdewolff/minify/xml"
+)
+
+//AssetsDir is the directory at the project root where the asset pipeline looks
+// for files first.
+const AssetsDir = "_assets"
+
+//Asset is a file processed by the asset pipeline of the templates, like
+//
+//	{{$css := asset "css/style.css" | minify | fingerprint}}
+//	<link rel="stylesheet" href="{{$css.URL}}" integrity="{{$css.Integrity}}">
+//
+// The asset is written to the output directory when its URL is used.
+type Asset struct {
+	// Name is the path of the asset relative to the output directory.
+	Name    string
+	Content []byte
+
+	key string
+	set *assetSet
+}
+
+//URL returns the url of the asset, and writes it to the output directory.
+func (a *Asset) URL() string {
+	a.set.publish(a)
+	return "/" + a.Name
+}
+
+func (a *Asset) String() string {
+	return a.URL()
+}
+
+//Integrity returns the subresource integrity hash of the asset content, for the
+// integrity attribute of link and script tags.
+func (a *Asset) Integrity() string {
+	sum := sha512.Sum384(a.Content)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// assetSet is the asset pipeline of a build. Assets are cached, since the same
+// pipeline usually runs for every page.
+type assetSet struct {
+	root  string
+	theme string
+	out   string
+	write func(file string, b []byte) error
+
+	mu        sync.Mutex
+	cache     map[string]*Asset
+	published map[string]bool
+	err       error
+}
+
+func newAssetSet(root, theme, out string, write func(string, []byte) error) *assetSet {
+	return &assetSet{
+		root:      root,
+		theme:     theme,
+		out:       out,
+		write:     write,
+		cache:     make(map[string]*Asset),
+		published: make(map[string]bool),
+	}
+}
+
+// funcs returns the asset pipeline template functions.
+func (s *assetSet) funcs() map[string]interface{} {
+	return map[string]interface{}{
+		"asset":       s.asset,
+		"bundle":      s.bundle,
+		"minify":      s.minify,
+		"fingerprint": s.fingerprint,
+	}
+}
+
+// cached returns the asset stored under key, or stores the one returned by fn.
+func (s *assetSet) cached(key string, fn func() (*Asset, error)) (*Asset, error) {
+	if s == nil {
+		return nil, fmt.Errorf("assets are only available when rendering")
+	}
+	s.mu.Lock()
+	a, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok {
+		return a, nil
+	}
+	a, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	a.key, a.set = key, s
+	s.mu.Lock()
+	s.cache[key] = a
+	s.mu.Unlock()
+	return a, nil
+}
+
+// asset returns the file name, which is looked up in the assets directory, the
+// theme directory and the project root in that order.
+func (s *assetSet) asset(name string) (*Asset, error) {
+	return s.cached("asset:"+name, func() (*Asset, error) {
+		b, err := s.read(name)
+		if err != nil {
+			return nil, err
+		}
+		return &Asset{Name: path.Clean(name), Content: b}, nil
+	})
+}
+
+// bundle returns the asset name, with the content of files joined together.
+func (s *assetSet) bundle(name string, files ...string) (*Asset, error) {
+	return s.cached("bundle:"+name+":"+strings.Join(files, ","), func() (*Asset, error) {
+		buf := &bytes.Buffer{}
+		for _, f := range files {
+			b, err := s.read(f)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(b)
+			if !bytes.HasSuffix(b, []byte("\n")) {
+				buf.WriteByte('\n')
+			}
+		}
+		return &Asset{Name: path.Clean(name), Content: buf.Bytes()}, nil
+	})
+}
+
+// minify returns a minified copy of a, the type of the content is taken from the
+// name of the asset.
+func (s *assetSet) minify(a *Asset) (*Asset, error) {
+	return s.cached("minify:"+a.key, func() (*Asset, error) {
+		typ := mime.TypeByExtension(path.Ext(a.Name))
+		b, err := newMinifier().Bytes(typ, a.Content)
+		if err != nil {
+			return nil, fmt.Errorf("minifying %s %v", a.Name, err)
+		}
+		return &Asset{Name: a.Name, Content: b}, nil
+	})
+}
+
+// fingerprint returns a copy of a, with the hash of its content in the name.
+func (s *assetSet) fingerprint(a *Asset) (*Asset, error) {
+	return s.cached("fingerprint:"+a.key, func() (*Asset, error) {
+		sum := sha256.Sum256(a.Content)
+		ext := path.Ext(a.Name)
+		name := fmt.Sprintf("%s.%x%s", strings.TrimSuffix(a.Name, ext), sum[:8], ext)
+		return &Asset{Name: name, Content: a.Content}, nil
+	})
+}
+
+// read returns the content of the asset file name.
+func (s *assetSet) read(name string) ([]byte, error) {
+	clean := path.Clean("/" + filepath.ToSlash(name))[1:]
+	if clean == "" || clean != strings.TrimPrefix(filepath.ToSlash(name), "/") {
+		return nil, fmt.Errorf("asset %s is outside the project", name)
+	}
+	dirs := []string{filepath.Join(s.root, AssetsDir), filepath.Join(s.root, ThemeDir, s.theme), s.root}
+	for _, dir := range dirs {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(clean)))
+		if err == nil {
+			return b, nil
+		}
+	}
+	if s.theme == defaultTheme {
+		if b, err := gh.Asset(clean); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("asset %s not found", name)
+}
+
+// publish writes a to the output directory, the first time it is called for
+// the asset name. Errors are kept, and returned by the build.
+func (s *assetSet) publish(a *Asset) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file := filepath.Join(s.out, filepath.FromSlash(a.Name))
+	if s.published[file] {
+		return
+	}
+	s.published[file] = true
+	if err := s.write(file, a.Content); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+// isPublished returns true if the file in the output directory was written by
+// the asset pipeline, so that static files don't overwrite it.
+func (s *assetSet) isPublished(file string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.published[file]
+}
+
+// newMinifier returns the minifier for the supported content types. CSS and
+// javascript inside html are minified too. The html minifier leaves the content
+// of pre elements alone.
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.AddFunc("text/css", css.Minify)
+	m.AddFunc("application/javascript", js.Minify)
+	m.AddFunc("text/javascript", js.Minify)
+	m.AddFunc("image/svg+xml", svg.Minify)
+	m.Add("text/html", &html.Minifier{KeepDocumentTags: true, KeepEndTags: true, KeepDefaultAttrVals: true})
+	m.AddFuncRegexp(regexp.MustCompile("[/+]json$"), json.Minify)
+	m.AddFuncRegexp(regexp.MustCompile("[/+]xml$"), xml.Minify)
+	return m
+}
+
+// minifyFile returns the minified content b of file, the content type is taken
+// from the file extension. Files of other types are returned as they are.
+func minifyFile(file string, b []byte) ([]byte, error) {
+	typ := mime.TypeByExtension(filepath.Ext(file))
+	if typ == "" {
+		return b, nil
+	}
+	out, err := newMinifier().Bytes(typ, b)
+	if err == minify.ErrNotExist {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("minifying %s %v", file, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,115 @@
+package bongo
+
+import (
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAssets(t *testing.T) {
+	files := map[string]string{
+		"_assets/css/reset.css":            "body {\n  margin: 0;\n}\n",
+		"_themes/plain/static/css/app.css": "h1 {\n  color: red;\n}\n",
+		"_themes/plain/home.html": `{{$css := bundle "static/css/site.css" "css/reset.css" "static/css/app.css" | minify | fingerprint}}` +
+			`<link href="{{$css.URL}}" integrity="{{$css.Integrity}}">` +
+			`<link href="{{asset "static/css/app.css" | minify}}">`,
+	}
+	dir := writeProject(t, plainTheme(files))
+	defer os.RemoveAll(dir)
+	err := New().Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, OutputDir)
+	b, err := ioutil.ReadFile(filepath.Join(out, indexPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := regexp.MustCompile(`href="(/static/css/site\.[0-9a-f]{16}\.css)" integrity="(sha384-[^"]+)"`).FindStringSubmatch(string(b))
+	if m == nil {
+		t.Fatalf("expected a fingerprinted bundle got %s", b)
+	}
+	bundle, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(m[1])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bundle), "margin") || !strings.Contains(string(bundle), "color") || strings.Contains(string(bundle), "\n") {
+		t.Errorf("expected the minified bundle got %q", bundle)
+	}
+	if a := (&Asset{Content: bundle}); a.Integrity() != html.UnescapeString(m[2]) {
+		t.Errorf("expected integrity %s got %s", a.Integrity(), m[2])
+	}
+
+	// the minified asset takes the place of the static file.
+	app, err := ioutil.ReadFile(filepath.Join(out, "static", "css", "app.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(app), "\n") {
+		t.Errorf("expected the static file to be minified got %q", app)
+	}
+
+	s := newAssetSet(dir, "plain", out, nil)
+	if _, err = s.asset("../secret.css"); err == nil {
+		t.Error("expected an error for an asset outside the project")
+	}
+}
+
+func TestMissingAsset(t *testing.T) {
+	// the template errors say which page, section index or home page failed.
+	sample := map[string]string{
+		"post.html":  "post.md",
+		"index.html": "section blog",
+		"home.html":  "home",
+	}
+	for tpl, where := range sample {
+		files := map[string]string{
+			"post.md":              "---\ntitle: Post\nsection: blog\n---\nPost",
+			"_themes/plain/" + tpl: `<link href="{{asset "css/missing.css"}}">`,
+		}
+		dir := writeProject(t, plainTheme(files))
+		err := New().Run(dir)
+		os.RemoveAll(dir)
+		if err == nil {
+			t.Errorf("%s: expected an error for a missing asset", tpl)
+			continue
+		}
+		if where == "post.md" {
+			where = filepath.Join(dir, where)
+		}
+		for _, v := range []string{where + ": ", "asset css/missing.css not found"} {
+			if !strings.Contains(err.Error(), v) {
+				t.Errorf("%s: expected %q in the error got %v", tpl, v, err)
+			}
+		}
+	}
+}
+
+func TestMinifyOutput(t *testing.T) {
+	files := map[string]string{
+		DefaultConfigFile:         "theme: plain\nminify: true\n",
+		"_themes/plain/post.html": "<html>\n  <body>\n    {{.Page.HTML}}\n  </body>\n</html>\n",
+		"code.md":                 "---\ntitle: code\n---\n\n```\nfunc main() {\n    run()\n}\n```\n",
+	}
+	dir := writeProject(t, plainTheme(files))
+	defer os.RemoveAll(dir)
+	err := New().Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, OutputDir, "home", "code.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(b)
+	if !strings.HasPrefix(out, "<html><body>") {
+		t.Errorf("expected minified html got %q", out)
+	}
+	if !strings.Contains(out, "func main() {\n    run()\n}") {
+		t.Errorf("expected the code to keep its whitespace got %q", out)
+	}
+}
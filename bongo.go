@@ -1,6 +1,13 @@
 package bongo
 
-import "os"
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
 
 type defaultApp struct {
 	DefaultLoader
@@ -15,9 +22,32 @@ func newDefaultApp() *defaultApp {
 	return app
 }
 
+// Load loads the files in base, except the ones in the output directory.
+func (d *defaultApp) Load(base string) ([]string, error) {
+	loader := d.DefaultLoader
+	if dest := d.Destination(); dest != "" {
+		loader.Exclude = []string{dest}
+	}
+	return loader.Load(base)
+}
+
 //App is the main bongo application
 type App struct {
 	gene Generator
+
+	// Options are passed to the Generator when rendering.
+	Options Options
+
+	// root and pages are the project and pages of the last build.
+	root  string
+	pages PageList
+
+	// search is the full text index of the pages, it is created on demand.
+	search *SearchIndex
+
+	// plugins are the plugins of the current build, the registered ones and
+	// the external plugins of the configuration.
+	plugins plugins
 }
 
 //New creates a new App which uses default Generator implementation
@@ -32,10 +62,172 @@ func NewApp(g Generator) *App {
 
 // Run runs the app
 func (g *App) Run(root string) error {
-	files, err := g.gene.Load(root)
+	g.root = root
+
+	// run before rendering
+	defer g.closePlugins()
+	err := g.before(root)
+	if err != nil {
+		return err
+	}
+	pages, err := g.loadPages(root)
 	if err != nil {
 		return err
 	}
+	g.pages = pages
+
+	opts := g.Options
+	opts.Plugins = g.plugins
+	err = g.gene.Render(root, pages, opts)
+	if err != nil {
+		cleanBuild(root, g.destination(root)) // roll back before exiting
+		return err
+	}
+	if g.search != nil {
+		g.search.Update(pages)
+	}
+
+	// run after rendering
+	err = g.gene.After(root)
+	if err != nil {
+		return err
+	}
+	err = g.plugins.onBuildDone(g.destination(root), pages)
+	if err != nil {
+		return err
+	}
+	if g.Options.Check {
+		return g.checkBuild(root, pages)
+	}
+	return nil
+
+}
+
+// before passes the Options to the Generator, and calls its Before method. The
+// plugins get the configuration of Generators which provide it, and the
+// external plugins listed in it run after the registered ones.
+func (g *App) before(root string) error {
+	if c, ok := g.gene.(Configurable); ok {
+		c.SetOptions(g.Options)
+	}
+	g.plugins = plugins(g.Options.Plugins)
+	if err := g.gene.Before(root); err != nil {
+		return err
+	}
+	if c, ok := g.gene.(ConfigProvider); ok && c.Config() != nil {
+		ext, err := externalPlugins(root, c.Config())
+		if err != nil {
+			return err
+		}
+		g.plugins = append(g.plugins[:len(g.plugins):len(g.plugins)], ext...)
+		return g.plugins.onConfig(c.Config())
+	}
+	return nil
+}
+
+// closePlugins closes the external plugins, the registered ones are kept for
+// the next run.
+func (g *App) closePlugins() {
+	if len(g.plugins) > len(g.Options.Plugins) {
+		g.plugins[len(g.Options.Plugins):].close()
+	}
+}
+
+// destination returns the directory the site at root is generated in.
+func (g *App) destination(root string) string {
+	if d, ok := g.gene.(Destinationer); ok && d.Destination() != "" {
+		return d.Destination()
+	}
+	return filepath.Join(root, OutputDir)
+}
+
+//Destination returns the directory the site of the last build was generated in.
+func (g *App) Destination() string {
+	return g.destination(g.root)
+}
+
+//SearchIndex returns the full text index of the pages of the last build, it is
+// kept up to date by the following builds.
+func (g *App) SearchIndex() *SearchIndex {
+	if g.search == nil {
+		g.search = NewSearchIndex()
+		g.search.Update(g.pages)
+	}
+	return g.search
+}
+
+// Dependencies returns the markdown files of the last build, and the files
+// they depend on like included code snippets, data and translation files. The
+// directories in the data and i18n directories are included, so that new files
+// can be noticed, and so are the configuration and content files.
+func (g *App) Dependencies() []string {
+	var rst []string
+	for _, p := range g.pages {
+		if !p.virtual {
+			rst = append(rst, p.Path)
+		}
+		rst = append(rst, p.Dependencies()...)
+	}
+	if g.root != "" {
+		rst = append(rst, filepath.Join(g.root, DefaultConfigFile), filepath.Join(g.root, ContentFile))
+		if g.Options.Environment != "" {
+			rst = append(rst, filepath.Join(g.root, EnvConfigFile(g.Options.Environment)))
+		}
+		for _, dir := range []string{DataDir, I18nDir} {
+			filepath.Walk(filepath.Join(g.root, dir), func(path string, info os.FileInfo, err error) error {
+				if err == nil {
+					rst = append(rst, path)
+				}
+				return nil
+			})
+		}
+	}
+	return rst
+}
+
+// Check verifies links in the site generated at root, without building it.
+func (g *App) Check(root string, opts CheckOptions) (CheckReport, error) {
+	defer g.closePlugins()
+	if err := g.before(root); err != nil {
+		return nil, err
+	}
+	pages, err := g.loadPages(root)
+	if err != nil {
+		return nil, err
+	}
+	return CheckSite(g.destination(root), pageSources(pages), opts)
+}
+
+func (g *App) checkBuild(root string, pages PageList) error {
+	report, err := CheckSite(g.destination(root), pageSources(pages), g.Options.CheckOptions)
+	if err != nil {
+		return err
+	}
+	if report.Len() == 0 {
+		return nil
+	}
+	if g.Options.Strict {
+		return fmt.Errorf("found %d broken links\n%s", report.Len(), report)
+	}
+	log.Printf("WARNING found %d broken links\n%s", report.Len(), report)
+	return nil
+}
+
+// pageSources maps the url of every page to its markdown file.
+func pageSources(pages PageList) map[string]string {
+	rst := make(map[string]string)
+	for _, p := range pages {
+		rst["/"+filepath.ToSlash(urlFile(p.URL()))] = p.Path
+	}
+	return rst
+}
+
+// loadPages loads and parses all the pages found in root.
+func (g *App) loadPages(root string) (PageList, error) {
+	files, err := g.gene.Load(root)
+	if err != nil {
+		return nil, err
+	}
 	pages := make(PageList, len(files))
 	send := make(chan *Page)
 	errs := make(chan error)
@@ -47,7 +239,12 @@ func (g *App) Run(root string) error {
 				return
 			}
 			defer f.Close()
-			front, body, err := g.gene.Parse(f)
+			raw, err := ioutil.ReadAll(f)
+			if err != nil {
+				errs <- err
+				return
+			}
+			front, body, err := g.gene.Parse(bytes.NewReader(raw))
 			if err != nil {
 				errs <- err
 				return
@@ -57,7 +254,16 @@ func (g *App) Run(root string) error {
 				errs <- err
 				return
 			}
-			send <- &Page{Path: file, Body: body, Data: front, ModTime: stat.ModTime()}
+			b, err := ioutil.ReadAll(body)
+			if err != nil {
+				errs <- err
+				return
+			}
+			pg := &Page{Path: file, Body: b, Data: front, ModTime: stat.ModTime()}
+			if bytes.HasSuffix(raw, b) {
+				pg.line = bytes.Count(raw[:len(raw)-len(b)], []byte("\n"))
+			}
+			send <- pg
 		}(f)
 	}
 	n := 0
@@ -80,25 +286,11 @@ END:
 
 	}
 	if fish != nil {
-		return fish
+		return nil, fish
 	}
-
-	// run before rendering
-	err = g.gene.Before(root)
+	virtual, err := g.contentPages(root, pages)
 	if err != nil {
-		return nil
-	}
-	err = g.gene.Render(root, pages)
-	if err != nil {
-		Rollback(root) // roll back before exiting
-		return err
-	}
-
-	// run after rendering
-	err = g.gene.After(root)
-	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
-
+	return g.plugins.onPagesLoaded(append(pages, virtual...))
 }
@@ -1,6 +1,47 @@
 package bongo
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeProject writes files, keyed by their slash separated path, in a new
+// temporary directory and returns the directory.
+func writeProject(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "bongo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(file), 0755); err == nil {
+			err = ioutil.WriteFile(file, []byte(v), 0644)
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// plainTheme returns files with the configuration and the templates of the
+// theme plain, which render nothing, unless files has them.
+func plainTheme(files map[string]string) map[string]string {
+	rst := map[string]string{
+		DefaultConfigFile:          "theme: plain\n",
+		"_themes/plain/post.html":  ``,
+		"_themes/plain/index.html": ``,
+		"_themes/plain/home.html":  ``,
+		"_themes/plain/page.html":  ``,
+	}
+	for name, v := range files {
+		rst[name] = v
+	}
+	return rst
+}
 
 func TestApp(t *testing.T) {
 	app := New()
@@ -9,3 +50,71 @@ func TestApp(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestAppDestination(t *testing.T) {
+	files := map[string]string{
+		DefaultConfigFile:     "output: public\n",
+		"post.md":             "---\ntitle: Post\n---\n# Post\n",
+		"public/old.md":       "---\ntitle: Old\n---\n# Old\n",
+		"notes_site/draft.md": "---\ntitle: Draft\n---\n# Draft\n",
+	}
+	dir := writeProject(t, files)
+	defer os.RemoveAll(dir)
+
+	app := New()
+	err := app.Run(dir)
+	if err == nil {
+		t.Fatal("expected an error for an output directory not generated by bongo")
+	}
+	if _, err = os.Stat(filepath.Join(dir, "public", "old.md")); err != nil {
+		t.Fatalf("expected the output directory to be kept %v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "public", BuildMarker), nil, DefaultPerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = app.Run(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "public", "old.md")); !os.IsNotExist(err) {
+		t.Errorf("expected the previous build to be removed got %v", err)
+	}
+	if app.Destination() != filepath.Join(dir, "public") {
+		t.Errorf("unexpected destination %s", app.Destination())
+	}
+	if len(app.pages) != 2 {
+		t.Errorf("expected the output directory to be skipped got %d pages", len(app.pages))
+	}
+	info, err := os.Stat(filepath.Join(dir, "public", "home", "post.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != DefaultPerm {
+		t.Errorf("expected mode %o got %o", DefaultPerm, info.Mode().Perm())
+	}
+
+	app.Options.Destination = dir
+	if err = app.Run(dir); err == nil {
+		t.Error("expected an error for the project root as destination")
+	}
+}
+
+func TestAppUnmarkedOutput(t *testing.T) {
+	// the output of a build by a bongo version which didn't write the marker.
+	dir := writeProject(t, plainTheme(map[string]string{
+		"post.md":              "---\ntitle: Post\n---\n# Post\n",
+		"_site/index.html":     "old home",
+		"_site/blog/gone.html": "old post",
+	}))
+	defer os.RemoveAll(dir)
+	err := New().Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, OutputDir, "blog", "gone.html")); !os.IsNotExist(err) {
+		t.Errorf("expected the previous build to be removed got %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, OutputDir, BuildMarker)); err != nil {
+		t.Errorf("expected the output directory to be marked %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package bongo
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+//BundleIndexFile is the name of the markdown file of a page bundle. The other
+// files in the directory of a bundle are the resources of the page.
+const BundleIndexFile = "index.md"
+
+type (
+	//Resource is a file in the directory of a page bundle, like an image or an
+	// attachment of a post. Resources are copied next to the generated page, so
+	// relative links to them like ![](diagram.png) work.
+	Resource struct {
+		// Name is the path of the file relative to the bundle directory.
+		Name string
+
+		// Path is the source file.
+		Path string
+
+		page *Page
+	}
+
+	//Resources are the resources of a page bundle, sorted by name.
+	Resources []*Resource
+)
+
+//URL returns the url of the resource, in the directory of the page.
+func (r *Resource) URL() string {
+	u := r.page.URL()
+	return u[:strings.LastIndex(u, "/")+1] + r.Name
+}
+
+//Image returns the resource as an image, for image processing. It is nil when
+// the resource is not a JPEG, PNG or GIF file.
+func (r *Resource) Image() (*Image, error) {
+	if r.page.ctx == nil || r.page.ctx.images == nil || !HasExt(r.Name, imageExtensions...) {
+		return nil, nil
+	}
+	rel, err := filepath.Rel(r.page.ctx.root, r.Path)
+	if err != nil {
+		return nil, err
+	}
+	return r.page.ctx.images.image(rel)
+}
+
+//Match returns the resources with a name matching the shell pattern, like
+// *.png or images/* .
+func (r Resources) Match(pattern string) (Resources, error) {
+	var rst Resources
+	for _, v := range r {
+		ok, err := path.Match(pattern, v.Name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rst = append(rst, v)
+		}
+	}
+	return rst, nil
+}
+
+//Get returns the resource with the name, or nil if there is none.
+func (r Resources) Get(name string) *Resource {
+	for _, v := range r {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+//Resources returns the files of the page bundle, it is empty for pages which
+// are not bundles.
+func (p *Page) Resources() Resources {
+	return p.resources
+}
+
+// isBundle returns true if the page is the index.md file of a directory in
+// the project.
+func (p *Page) isBundle() bool {
+	return p.key != "" && path.Base(p.key) == BundleIndexFile && path.Dir(p.key) != "."
+}
+
+// loadResources sets the resources of the page, which are the files in the
+// directory of the bundle except markdown files. Subdirectories with an
+// index.md are bundles of their own, and are skipped.
+func (p *Page) loadResources() error {
+	p.resources = nil
+	if !p.isBundle() || p.virtual {
+		return nil
+	}
+	dir := filepath.Dir(p.Path)
+	return filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case info.IsDir():
+			if _, err = os.Stat(filepath.Join(file, BundleIndexFile)); err == nil && file != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		case HasExt(file, supportedExtensions...):
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		p.resources = append(p.resources, &Resource{Name: filepath.ToSlash(rel), Path: file, page: p})
+		p.addDependency(file)
+		return nil
+	})
+}
@@ -0,0 +1,68 @@
+package bongo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPageBundles(t *testing.T) {
+	files := map[string]string{
+		"posts/trip/index.md":     "---\ntitle: Trip\nsection: blog\n---\n![map](map.png)\n",
+		"posts/trip/map.png":      "map",
+		"posts/trip/photos/a.png": "a",
+		"posts/trip/notes.txt":    "notes",
+		"posts/trip/day/index.md": "---\ntitle: Day\nsection: blog\n---\nDay",
+		"posts/trip/day/sun.png":  "sun",
+		"posts/other.md":          "---\ntitle: Other\nsection: blog\n---\n[trip](trip/index.md)\n",
+		"_themes/plain/post.html": `{{.Page.HTML}}{{range .Page.Resources.Match "*.png"}}<a href="{{.URL}}">{{.Name}}</a>{{end}}`,
+	}
+	dir := writeProject(t, plainTheme(files))
+	defer os.RemoveAll(dir)
+	app := New()
+	err := app.Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, OutputDir)
+	expect := map[string][]string{
+		"blog/trip/index.html": {`<img src="map.png" alt="map">`, `<a href="/blog/trip/map.png">map.png</a>`},
+		"blog/other.html":      {`<a href="/blog/trip/">trip</a>`},
+	}
+	for name, v := range expect {
+		b, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range v {
+			if !strings.Contains(string(b), s) {
+				t.Errorf("expected %s to contain %s got %s", name, s, b)
+			}
+		}
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(out, "blog", "trip", "index.html")); strings.Contains(string(b), "a.png") {
+		t.Errorf("expected *.png not to match photos/a.png got %s", b)
+	}
+	for _, name := range []string{"blog/trip/map.png", "blog/trip/photos/a.png", "blog/trip/notes.txt"} {
+		if _, err = os.Stat(filepath.Join(out, filepath.FromSlash(name))); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(out, "blog", "trip", "index.md")); err == nil {
+		t.Error("expected the markdown file not to be copied")
+	}
+
+	// the day bundle in the trip bundle has its own resources.
+	for _, p := range app.pages {
+		var names []string
+		for _, r := range p.Resources() {
+			names = append(names, r.Name)
+		}
+		expect := map[string]string{"Trip": "map.png notes.txt photos/a.png", "Day": "sun.png"}[p.Title()]
+		if got := strings.Join(names, " "); got != expect {
+			t.Errorf("expected the resources of %s to be %q got %q", p.Title(), expect, got)
+		}
+	}
+}
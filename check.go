@@ -0,0 +1,249 @@
+package bongo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// linkAttrs are the attributes checked for links, by element name.
+var linkAttrs = map[string]string{
+	"a":      "href",
+	"link":   "href",
+	"area":   "href",
+	"img":    "src",
+	"script": "src",
+	"iframe": "src",
+	"source": "src",
+	"video":  "src",
+	"audio":  "src",
+}
+
+type (
+	//CheckOptions configures the link checker.
+	CheckOptions struct {
+		// External enables checking of external links. Only links matching a
+		// prefix in Allow are considered valid, no network requests are made.
+		External bool
+		Allow    []string
+	}
+
+	//BrokenLink is a link in a generated page whose target can't be found.
+	BrokenLink struct {
+		// Page is the generated file, relative to the output directory.
+		Page   string
+		Link   string
+		Reason string
+	}
+
+	//CheckReport lists broken links grouped by the markdown file of the page
+	// they were found in. Generated pages without a markdown source, like
+	// section indexes are grouped by their own path.
+	CheckReport map[string][]*BrokenLink
+
+	// htmlDoc holds what the checker needs to know about a generated page.
+	htmlDoc struct {
+		ids   map[string]bool
+		links []string
+	}
+)
+
+// Len returns the total number of broken links in the report.
+func (r CheckReport) Len() int {
+	n := 0
+	for _, v := range r {
+		n += len(v)
+	}
+	return n
+}
+
+func (r CheckReport) String() string {
+	var keys []string
+	for k := range r {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf := &bytes.Buffer{}
+	for _, k := range keys {
+		fmt.Fprintln(buf, k)
+		for _, b := range r[k] {
+			fmt.Fprintf(buf, "\t%s: %s (%s)\n", b.Page, b.Link, b.Reason)
+		}
+	}
+	return buf.String()
+}
+
+// LoadAllowList reads a list of allowed external url prefixes, one per line.
+// Empty lines and lines starting with # are ignored.
+func LoadAllowList(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rst []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rst = append(rst, line)
+	}
+	return rst, s.Err()
+}
+
+// CheckSite walks the generated site in dir, and verifies that every internal
+// link points to an existing file, and that fragments match an element id in
+// the target page. sources maps page urls to the markdown files they were
+// generated from, and is used to group the report.
+func CheckSite(dir string, sources map[string]string, opts CheckOptions) (CheckReport, error) {
+	docs := make(map[string]*htmlDoc)
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !HasExt(file, DefaultExt, ".htm") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		doc, err := parseHTMLDoc(file)
+		if err != nil {
+			return err
+		}
+		docs["/"+filepath.ToSlash(rel)] = doc
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report := make(CheckReport)
+	for page, doc := range docs {
+		for _, link := range doc.links {
+			reason := checkLink(dir, page, link, docs, opts)
+			if reason == "" {
+				continue
+			}
+			src := page
+			if s, ok := sources[page]; ok {
+				src = s
+			}
+			report[src] = append(report[src], &BrokenLink{Page: page, Link: link, Reason: reason})
+		}
+	}
+	for _, v := range report {
+		sort.Sort(brokenLinks(v))
+	}
+	return report, nil
+}
+
+// checkLink returns the reason link found in page is broken, or an empty
+// string when the link is fine.
+func checkLink(dir, page, link string, docs map[string]*htmlDoc, opts CheckOptions) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "malformed url"
+	}
+	switch {
+	case u.Scheme == "http" || u.Scheme == "https" || (u.Scheme == "" && u.Host != ""):
+		if !opts.External {
+			return ""
+		}
+		for _, prefix := range opts.Allow {
+			if strings.HasPrefix(link, prefix) {
+				return ""
+			}
+		}
+		return "external link not in the allow list"
+	case u.Scheme != "":
+		// mailto, data, javascript etc.
+		return ""
+	}
+	target := page
+	if u.Path != "" {
+		target = u.Path
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(page), target)
+		}
+		if strings.HasSuffix(u.Path, "/") {
+			target = path.Join(target, indexPage)
+		}
+		target = path.Clean(target)
+	}
+	doc, ok := docs[target]
+	if !ok {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(target)))
+		if err != nil {
+			return "missing target"
+		}
+		if !info.IsDir() {
+			return ""
+		}
+		target = path.Join(target, indexPage)
+		if doc, ok = docs[target]; !ok {
+			return "missing target"
+		}
+	}
+	if u.Fragment != "" && !doc.ids[u.Fragment] {
+		return "missing anchor #" + u.Fragment
+	}
+	return ""
+}
+
+func parseHTMLDoc(file string) (*htmlDoc, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	node, err := html.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+	doc := &htmlDoc{ids: make(map[string]bool)}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			key := linkAttrs[n.Data]
+			for _, a := range n.Attr {
+				switch {
+				case a.Key == "id":
+					doc.ids[a.Val] = true
+				case a.Key == "name" && n.Data == "a":
+					doc.ids[a.Val] = true
+				case a.Key == key && key != "":
+					if a.Val != "" {
+						doc.links = append(doc.links, a.Val)
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(node)
+	return doc, nil
+}
+
+type brokenLinks []*BrokenLink
+
+func (b brokenLinks) Len() int      { return len(b) }
+func (b brokenLinks) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b brokenLinks) Less(i, j int) bool {
+	if b[i].Page != b[j].Page {
+		return b[i].Page < b[j].Page
+	}
+	return b[i].Link < b[j].Link
+}
@@ -0,0 +1,43 @@
+package bongo
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCheckSite(t *testing.T) {
+	files := map[string]string{
+		"index.html":      `<a href="/blog/">blog</a><a href="https://golang.org/doc">go</a><a href="https://example.com">ex</a>`,
+		"blog/index.html": `<a href="one.html#intro">one</a><a href="missing.html">missing</a>`,
+		"blog/one.html":   `<h1 id="intro">Intro</h1><a href="#outro">outro</a><img src="../media/logo.png"><a href="mailto:me@example.com">me</a>`,
+	}
+	dir := writeProject(t, files)
+	defer os.RemoveAll(dir)
+	sources := map[string]string{"/blog/one.html": "one.md"}
+	report, err := CheckSite(dir, sources, CheckOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Len() != 3 {
+		t.Fatalf("expected 3 broken links got %d\n%s", report.Len(), report)
+	}
+	if len(report["/blog/index.html"]) != 1 {
+		t.Errorf("expected missing.html to be reported got %v", report)
+	}
+	one := report["one.md"]
+	if len(one) != 2 {
+		t.Fatalf("expected 2 broken links for one.md got %d", len(one))
+	}
+	if one[0].Link != "#outro" || one[1].Link != "../media/logo.png" {
+		t.Errorf("unexpected broken links %v %v", one[0], one[1])
+	}
+
+	report, err = CheckSite(dir, sources, CheckOptions{External: true, Allow: []string{"https://golang.org/"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := report["/index.html"]
+	if len(home) != 1 || home[0].Link != "https://example.com" {
+		t.Errorf("expected https://example.com to be reported got %v", home)
+	}
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"text/tabwriter"
 
 	"github.com/gernest/bongo"
 
@@ -18,17 +19,106 @@ var (
 		{"Geofrey Ernest", "geofreyernest@live.com"},
 	}
 	sourceFlagName = "source"
+	strictFlagName = "strict"
+	checkFlagName  = "check"
+	externalFlag   = "external"
+	allowFlagName  = "allow"
+	styleFlagName  = "style"
+	envFlagName    = "environment"
+	destFlagName   = "destination"
+	minifyFlagName = "minify"
 	appName        = "bongo"
 	version        = "0.1.1"
 )
 
 func buildFlags() []cli.Flag {
+	return append([]cli.Flag{
+		cli.BoolFlag{
+			Name:  strictFlagName,
+			Usage: "treat build warnings like unresolved links as errors",
+		},
+		cli.BoolFlag{
+			Name:  checkFlagName,
+			Usage: "check the generated site for broken links",
+		},
+		cli.BoolFlag{
+			Name:  minifyFlagName,
+			Usage: "minify the generated pages",
+		},
+	}, checkFlags()...)
+}
+
+func envFlag() cli.Flag {
+	return cli.StringFlag{
+		Name:   envFlagName,
+		Usage:  "reads the configuration of the environment from _bongo.<environment>.yml",
+		EnvVar: bongo.EnvironmentVar,
+	}
+}
+
+func checkFlags() []cli.Flag {
 	return []cli.Flag{
 		cli.StringFlag{
 			Name:   sourceFlagName,
 			Usage:  "sets the path to the project soucce files",
 			EnvVar: "PROJECT_SOURCE",
 		},
+		cli.BoolFlag{
+			Name:  externalFlag,
+			Usage: "check external links against the allow list",
+		},
+		cli.StringFlag{
+			Name:  allowFlagName,
+			Usage: "path to a file listing allowed external url prefixes, one per line",
+		},
+		cli.StringFlag{
+			Name:  destFlagName,
+			Usage: "sets the directory the site is generated in",
+		},
+		envFlag(),
+	}
+}
+
+func checkOptions(ctx *cli.Context) bongo.CheckOptions {
+	opts := bongo.CheckOptions{External: ctx.Bool(externalFlag)}
+	if f := ctx.String(allowFlagName); f != "" {
+		allow, err := bongo.LoadAllowList(f)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.Allow = allow
+	}
+	return opts
+}
+
+// buildOptions returns the Options set by the flags of build and serve.
+func buildOptions(ctx *cli.Context) bongo.Options {
+	return bongo.Options{
+		Strict:       ctx.Bool(strictFlagName),
+		Check:        ctx.Bool(checkFlagName),
+		CheckOptions: checkOptions(ctx),
+		Environment:  ctx.String(envFlagName),
+		Destination:  ctx.String(destFlagName),
+		Minify:       ctx.Bool(minifyFlagName),
+	}
+}
+
+func check(ctx *cli.Context) {
+	wd, _ := os.Getwd()
+	src := wd
+	if f := ctx.String(sourceFlagName); f != "" {
+		src = f
+	}
+	app := bongo.New()
+	app.Options.Environment = ctx.String(envFlagName)
+	app.Options.Destination = ctx.String(destFlagName)
+	report, err := app.Check(src, checkOptions(ctx))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if report.Len() > 0 {
+		fmt.Print(report)
+		log.Fatalf("found %d broken links", report.Len())
 	}
 }
 
@@ -39,6 +129,7 @@ func build(ctx *cli.Context) {
 		src = f
 	}
 	app := bongo.New()
+	app.Options = buildOptions(ctx)
 	err := app.Run(src)
 	if err != nil {
 		log.Println(err)
@@ -53,26 +144,33 @@ func serve(ctx *cli.Context) {
 		src = f
 	}
 	app := bongo.New()
+	app.Options = buildOptions(ctx)
 	err := app.Run(src)
 	if err != nil {
 		log.Println(err)
 	}
-	files, err := bongo.NewLoader().Load(src)
-	if err != nil {
-		log.Fatal(err)
-	}
 	watch, err := fsnotify.NewWatcher()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer watch.Close()
-	for _, file := range files {
-		watch.Add(file)
+
+	// watch the markdown files, and the files they include.
+	watchFiles := func() {
+		for _, file := range app.Dependencies() {
+			watch.Add(file)
+		}
 	}
+	watchFiles()
+	search := app.SearchIndex()
 	go func() {
-		dir := filepath.Join(src, bongo.OutputDir)
+		dir := app.Destination()
+		mux := http.NewServeMux()
+		mux.Handle(bongo.SearchPath, search)
+		mux.Handle("/", http.FileServer(http.Dir(dir)))
 		log.Println("serving website", dir, "  at  http://localhost:8000")
-		log.Fatal(http.ListenAndServe(":8000", http.FileServer(http.Dir(dir))))
+		log.Println("search the pages at http://localhost:8000" + bongo.SearchPath + "?q=")
+		log.Fatal(http.ListenAndServe(":8000", mux))
 	}()
 	for {
 		select {
@@ -80,6 +178,7 @@ func serve(ctx *cli.Context) {
 			if event.Op&(fsnotify.Rename|fsnotify.Create|fsnotify.Write) > 0 {
 				log.Printf("detected change %s  Rebuilding...\n", event.Name)
 				app.Run(src)
+				watchFiles()
 			}
 		case err := <-watch.Errors:
 			if err != nil {
@@ -92,6 +191,30 @@ func serve(ctx *cli.Context) {
 
 }
 
+// printConfig prints the settings of the project, and where they were set.
+func printConfig(ctx *cli.Context) {
+	wd, _ := os.Getwd()
+	src := wd
+	if f := ctx.String(sourceFlagName); f != "" {
+		src = f
+	}
+	cfg, err := bongo.LoadConfig(src, ctx.String(envFlagName))
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, s := range cfg.Settings() {
+		fmt.Fprintf(w, "%s\t%v\t# %s\n", s.Key, s.Value, s.Source)
+	}
+	w.Flush()
+}
+
+func genCSS(ctx *cli.Context) {
+	if err := bongo.HighlightCSS(os.Stdout, ctx.String(styleFlagName)); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Name = appName
@@ -115,6 +238,46 @@ func main() {
 			Action:      serve,
 			Flags:       buildFlags(),
 		},
+		cli.Command{
+			Name:        "check",
+			ShortName:   "c",
+			Usage:       "checks the generated site for broken links",
+			Description: "checks links and anchors in the generated site",
+			Action:      check,
+			Flags:       checkFlags(),
+		},
+		cli.Command{
+			Name:        "config",
+			Usage:       "prints the configuration of the project",
+			Description: "prints the settings after merging the configuration files and environment variables, and where each one was set",
+			Action:      printConfig,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   sourceFlagName,
+					Usage:  "sets the path to the project soucce files",
+					EnvVar: "PROJECT_SOURCE",
+				},
+				envFlag(),
+			},
+		},
+		cli.Command{
+			Name:  "gen",
+			Usage: "generates files used by themes",
+			Subcommands: []cli.Command{
+				{
+					Name:   "css",
+					Usage:  "writes the stylesheet for highlighted code to stdout",
+					Action: genCSS,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  styleFlagName,
+							Value: bongo.DefaultHighlightStyle,
+							Usage: "the name of the highlight style",
+						},
+					},
+				},
+			},
+		},
 	}
 	app.Run(os.Args)
 }
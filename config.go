@@ -0,0 +1,628 @@
+package bongo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	//ParamsKey is the key used to store the params of the configuration in the
+	// template context
+	ParamsKey = "Params"
+
+	//SectionsFromFrontMatter is the value of SectionsModeKey for taking sections
+	// from the front matter, which is the default.
+	SectionsFromFrontMatter = "frontmatter"
+
+	//EnvironmentVar is the environment variable which selects the configuration
+	// file of the environment, when it is not given.
+	EnvironmentVar = "BONGO_ENV"
+
+	envPrefix     = "BONGO_"
+	sourceDefault = "default"
+
+	pageDate = "date"
+	pageSlug = "slug"
+)
+
+// permalinkTokens are the placeholders allowed in permalink patterns.
+var permalinkTokens = []string{":section", ":slug", ":filename", ":title", ":year", ":month", ":day"}
+
+//Config is the site wide configuration, read from _bongo.yml at the root of the
+// project.
+type Config struct {
+	Title    string `yaml:"title"`
+	Subtitle string `yaml:"subtitle"`
+	Author   string `yaml:"author"`
+
+	// BaseURL is the url the site is published at, either absolute like
+	// https://example.com/docs or a path like /docs.
+	BaseURL string `yaml:"baseURL"`
+
+	// Theme is the name of a theme in the _themes directory, or the default
+	// theme.
+	Theme string `yaml:"theme"`
+
+	// Static are directories relative to the project root, which are copied to
+	// the output directory as is.
+	Static []string `yaml:"static"`
+
+	// Output is the directory the site is generated in, relative to the
+	// project root.
+	Output string `yaml:"output"`
+
+	// FileMode and DirMode are the permissions of the generated files and
+	// directories.
+	FileMode os.FileMode `yaml:"fileMode"`
+	DirMode  os.FileMode `yaml:"dirMode"`
+
+	// Permalinks maps sections to url patterns of their pages, for instance
+	// /:year/:month/:slug/ . The pattern of a section applies to the sections
+	// nested in it too.
+	Permalinks map[string]string `yaml:"permalinks"`
+
+	// Minify minifies the generated html pages, with the css and javascript
+	// in them, and xml and json files.
+	Minify bool `yaml:"minify"`
+
+	Sections  string                  `yaml:"sections"`
+	Anchors   bool                    `yaml:"anchors"`
+	Summary   int                     `yaml:"summary"`
+	Highlight HighlightConfig         `yaml:"highlight"`
+	Menus     map[string][]*MenuEntry `yaml:"menus"`
+
+	// Images are the settings of the image processing of templates.
+	Images ImagesConfig `yaml:"images"`
+
+	// Search are the settings of the search index.
+	Search SearchConfig `yaml:"search"`
+
+	// Plugins are the external plugins, which run after the plugins
+	// registered on the App.
+	Plugins []*PluginConfig `yaml:"plugins"`
+
+	// Languages are the languages the site is published in, keyed by code.
+	// DefaultLanguage is the language of pages which don't have one, it is
+	// the language with the lowest weight when not set.
+	Languages       map[string]*Language `yaml:"languages"`
+	DefaultLanguage string               `yaml:"defaultLanguage"`
+
+	// Params are free form values for the templates.
+	Params map[string]interface{} `yaml:"params"`
+
+	// site are the settings as they are in the configuration files, for the
+	// templates. sources maps the keys of the settings to where they were set.
+	site    map[string]interface{}
+	sources map[string]string
+}
+
+//ConfigError is returned when the configuration file is not valid. It lists
+// all the problems found.
+type ConfigError struct {
+	File   string
+	Errors []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: invalid configuration\n\t%s", e.File, strings.Join(e.Errors, "\n\t"))
+}
+
+//DefaultConfig returns the configuration used when there is no configuration
+// file.
+func DefaultConfig() *Config {
+	return &Config{
+		Theme:     defaultTheme,
+		Output:    OutputDir,
+		FileMode:  DefaultPerm,
+		DirMode:   DefaultDirPerm,
+		Summary:   defaultSummaryWords,
+		Highlight: HighlightConfig{Style: DefaultHighlightStyle},
+		Images:    ImagesConfig{Widths: DefaultImageWidths, Quality: DefaultImageQuality, Cache: DefaultImageCache},
+		Search:    SearchConfig{File: DefaultSearchFile},
+		site:      make(map[string]interface{}),
+		sources:   make(map[string]string),
+	}
+}
+
+// defaultSettings are the settings of DefaultConfig, as they would be written
+// in the configuration file.
+func defaultSettings() map[string]interface{} {
+	return map[string]interface{}{
+		ThemeKey:     defaultTheme,
+		"output":     OutputDir,
+		"fileMode":   DefaultPerm,
+		"dirMode":    DefaultDirPerm,
+		SummaryKey:   defaultSummaryWords,
+		HighlightKey: map[string]interface{}{"style": DefaultHighlightStyle},
+		ImagesKey:    map[string]interface{}{"quality": DefaultImageQuality, "cache": DefaultImageCache, "widths": DefaultImageWidths},
+		SearchKey:    map[string]interface{}{"file": DefaultSearchFile},
+	}
+}
+
+//LoadConfig reads the configuration file at the root of the project, and the
+// configuration file of the environment env, _bongo.<env>.yml, on top of it.
+// When env is empty, it is taken from the BONGO_ENV environment variable.
+// Environment variables like BONGO_BASEURL or BONGO_PARAMS_ANALYTICS override
+// single settings.
+//
+// Missing settings get their default values, and unknown settings are logged.
+func LoadConfig(root, env string) (*Config, error) {
+	if env == "" {
+		env = os.Getenv(EnvironmentVar)
+	}
+	c := DefaultConfig()
+	c.set(defaultSettings(), sourceDefault)
+
+	files := []string{DefaultConfigFile}
+	if env != "" {
+		files = append(files, EnvConfigFile(env))
+	}
+	for _, name := range files {
+		b, err := ioutil.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+			if name != DefaultConfigFile {
+				log.Printf("WARNING no configuration file %s for environment %s\n", name, env)
+			}
+			continue
+		}
+		m := make(map[string]interface{})
+		if err = yaml.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if err = checkTypes(name, b); err != nil {
+			return nil, err
+		}
+		c.set(m, name)
+	}
+
+	for _, kv := range os.Environ() {
+		i := strings.Index(kv, "=")
+		if i < 0 || !strings.HasPrefix(kv, envPrefix) || kv[:i] == EnvironmentVar {
+			continue
+		}
+		m, err := c.envSetting(kv[:i], kv[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		c.set(m, kv[:i])
+	}
+
+	if err := c.decode(); err != nil {
+		return nil, err
+	}
+	if err := c.validate(root); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+//EnvConfigFile returns the name of the configuration file for the environment
+// env.
+func EnvConfigFile(env string) string {
+	ext := filepath.Ext(DefaultConfigFile)
+	return strings.TrimSuffix(DefaultConfigFile, ext) + "." + env + ext
+}
+
+// checkTypes returns an error if the settings in the configuration file b,
+// named name have the wrong types.
+func checkTypes(name string, b []byte) error {
+	if err := yaml.Unmarshal(b, &Config{}); err != nil {
+		if terr, ok := err.(*yaml.TypeError); ok {
+			return &ConfigError{File: name, Errors: terr.Errors}
+		}
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// set merges the settings m into the settings of c, maps are merged and other
+// values are replaced. source is recorded as the origin of the values.
+func (c *Config) set(m map[string]interface{}, source string) {
+	mergeSettings(c.site, normalize(m).(map[string]interface{}), "", source, c.sources)
+}
+
+func mergeSettings(dst, src map[string]interface{}, prefix, source string, sources map[string]string) {
+	for k, v := range src {
+		key := prefix + k
+		if sm, ok := v.(map[string]interface{}); ok {
+			if dm, ok := dst[k].(map[string]interface{}); ok {
+				mergeSettings(dm, sm, key+".", source, sources)
+				continue
+			}
+		}
+		for s := range sources {
+			if s == key || strings.HasPrefix(s, key+".") {
+				delete(sources, s)
+			}
+		}
+		dst[k] = v
+		setSources(v, key, source, sources)
+	}
+}
+
+func setSources(v interface{}, key, source string, sources map[string]string) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		sources[key] = source
+		return
+	}
+	for k, val := range m {
+		setSources(val, key+"."+k, source, sources)
+	}
+}
+
+// envSetting returns the settings for the environment variable name with the
+// value v. The name is matched to the settings without regard to case, so
+// BONGO_PARAMS_GOOGLE_ID sets params.google_id if it exists. The value is
+// read as yaml, so lists and booleans can be set too.
+func (c *Config) envSetting(name, v string) (map[string]interface{}, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimPrefix(name, envPrefix)), "_")
+	keys := envKeys(c.site, configKeys(), parts)
+
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(v), &value); err != nil || value == nil {
+		value = v
+	}
+	m := map[string]interface{}{keys[len(keys)-1]: value}
+	for i := len(keys) - 2; i >= 0; i-- {
+		m = map[string]interface{}{keys[i]: m}
+	}
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkTypes(name, b); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// envKeys returns the path of keys in m matching parts, the words of an
+// environment variable name. known are the keys m can have besides its own.
+func envKeys(m map[string]interface{}, known map[string]bool, parts []string) []string {
+	for n := len(parts); n > 0; n-- {
+		name := strings.Join(parts[:n], "_")
+		key, ok := "", false
+		for k := range m {
+			if strings.ToLower(k) == name {
+				key, ok = k, true
+			}
+		}
+		for k := range known {
+			if !ok && strings.ToLower(k) == name {
+				key, ok = k, true
+			}
+		}
+		if !ok {
+			continue
+		}
+		if n == len(parts) {
+			return []string{key}
+		}
+		sub, _ := m[key].(map[string]interface{})
+		return append([]string{key}, envKeys(sub, nil, parts[n:])...)
+	}
+	return []string{strings.Join(parts, "_")}
+}
+
+// decode sets the fields of c from the merged settings, and logs the
+// unknown ones.
+func (c *Config) decode() error {
+	b, err := yaml.Marshal(c.site)
+	if err != nil {
+		return err
+	}
+	if err = yaml.Unmarshal(b, c); err != nil {
+		return err
+	}
+	known := configKeys()
+	var unknown []string
+	for k := range c.site {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		log.Printf("WARNING %s: unknown setting %s, use params for custom values\n", c.source(k), k)
+	}
+	c.Params, _ = normalize(c.Params).(map[string]interface{})
+	if c.DefaultLanguage == "" && len(c.Languages) > 0 {
+		c.DefaultLanguage = c.languages()[0].Code
+	}
+	return nil
+}
+
+// source returns where the setting key was set, for settings with nested
+// values it is where the first of them was set.
+func (c *Config) source(key string) string {
+	if s, ok := c.sources[key]; ok {
+		return s
+	}
+	var keys []string
+	for k := range c.sources {
+		if strings.HasPrefix(k, key+".") {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	return c.sources[keys[0]]
+}
+
+//Setting is a single value of the configuration.
+type Setting struct {
+	// Key is the path of the setting, with dots between nested keys like
+	// highlight.style .
+	Key   string
+	Value interface{}
+
+	// Source is where the value was set, either default, a configuration file
+	// or an environment variable.
+	Source string
+}
+
+//Settings returns all the values of the configuration sorted by key, and
+// where they were set.
+func (c *Config) Settings() []*Setting {
+	var rst []*Setting
+	for k, src := range c.sources {
+		rst = append(rst, &Setting{Key: k, Value: settingValue(c.site, k), Source: src})
+	}
+	sort.Sort(settings(rst))
+	return rst
+}
+
+func settingValue(m map[string]interface{}, key string) interface{} {
+	var v interface{} = m
+	for _, k := range strings.Split(key, ".") {
+		sub, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = sub[k]
+	}
+	return v
+}
+
+type settings []*Setting
+
+func (s settings) Len() int           { return len(s) }
+func (s settings) Less(i, j int) bool { return s[i].Key < s[j].Key }
+func (s settings) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// configKeys returns the settings which can be set in the configuration file.
+func configKeys() map[string]bool {
+	rst := make(map[string]bool)
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("yaml"); tag != "" {
+			rst[strings.Split(tag, ",")[0]] = true
+		}
+	}
+	return rst
+}
+
+// validate checks the values of the configuration, root is the project root
+// which relative paths are resolved against.
+func (c *Config) validate(root string) error {
+	e := &ConfigError{File: DefaultConfigFile}
+	bad := func(key, format string, args ...interface{}) {
+		msg := key + ": " + fmt.Sprintf(format, args...)
+		if src := c.source(key); src != DefaultConfigFile && src != "" {
+			msg += " (set in " + src + ")"
+		}
+		e.Errors = append(e.Errors, msg)
+	}
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		switch {
+		case err != nil:
+			bad("baseURL", "%v", err)
+		case u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https":
+			bad("baseURL", "unsupported scheme %s", u.Scheme)
+		case u.Scheme == "" && !strings.HasPrefix(c.BaseURL, "/"):
+			bad("baseURL", "%s must be an absolute url or start with /", c.BaseURL)
+		}
+	}
+	if c.Theme == "" {
+		bad("theme", "must not be empty")
+	} else if c.Theme != defaultTheme {
+		info, err := os.Stat(filepath.Join(root, ThemeDir, c.Theme))
+		if err != nil || !info.IsDir() {
+			bad("theme", "%s is not installed in %s", c.Theme, ThemeDir)
+		}
+	}
+	for _, dir := range c.Static {
+		info, err := os.Stat(filepath.Join(root, dir))
+		if err != nil || !info.IsDir() {
+			bad("static", "%s is not a directory", dir)
+		}
+	}
+	if out := filepath.Clean(c.Output); c.Output == "" || out == "." {
+		bad("output", "must be a directory other than the project root")
+	}
+	if c.FileMode == 0 || c.FileMode&^os.ModePerm != 0 {
+		bad("fileMode", "%o is not a valid permission", c.FileMode)
+	}
+	if c.DirMode&0700 != 0700 || c.DirMode&^os.ModePerm != 0 {
+		bad("dirMode", "%o is not a valid permission, the owner needs all permissions", c.DirMode)
+	}
+	for sec, pattern := range c.Permalinks {
+		if err := checkPermalink(pattern); err != nil {
+			bad("permalinks", "%s: %v", sec, err)
+		}
+	}
+	switch c.Sections {
+	case "", SectionsFromFrontMatter, SectionsFromDirs:
+	default:
+		bad("sections", "must be %s or %s, got %s", SectionsFromFrontMatter, SectionsFromDirs, c.Sections)
+	}
+	if c.Summary < 0 {
+		bad("summary", "must not be negative")
+	}
+	if _, err := newHighlighter(c.Highlight); err != nil {
+		bad("highlight", "%v", err)
+	}
+	if c.Images.Quality < 1 || c.Images.Quality > 100 {
+		bad("images.quality", "must be between 1 and 100, got %d", c.Images.Quality)
+	}
+	for _, w := range c.Images.Widths {
+		if w <= 0 {
+			bad("images.widths", "must be positive, got %d", w)
+		}
+	}
+	if f := filepath.ToSlash(c.Search.File); f == "" || path.IsAbs(f) || strings.HasPrefix(path.Clean(f), "..") {
+		bad("search.file", "must be a path in the output directory, got %q", c.Search.File)
+	}
+	if c.Search.Words < 0 {
+		bad("search.words", "must not be negative")
+	}
+	names := make(map[string]bool)
+	for i, p := range c.Plugins {
+		if p == nil {
+			bad("plugins", "plugin %d is empty", i+1)
+			continue
+		}
+		for _, msg := range checkPlugin(p) {
+			bad("plugins", "%s: %s", p.Name, msg)
+		}
+		if names[p.Name] {
+			bad("plugins", "%s is listed twice", p.Name)
+		}
+		names[p.Name] = true
+	}
+	if len(c.Languages) > 0 {
+		if _, ok := c.Languages[c.DefaultLanguage]; !ok {
+			bad("defaultLanguage", "%s is not one of the languages", c.DefaultLanguage)
+		}
+	}
+	for code := range c.Languages {
+		if code == "" || strings.ContainsAny(code, "/\\. ") {
+			bad("languages", "%q is not a valid language code", code)
+		}
+	}
+	for name, entries := range c.Menus {
+		for i, m := range entries {
+			if m == nil || m.Name == "" {
+				bad("menus", "%s[%d]: name is required", name, i)
+			}
+		}
+	}
+	if len(e.Errors) > 0 {
+		sort.Strings(e.Errors)
+		return e
+	}
+	return nil
+}
+
+// sectionsFromDirs returns true if sections are taken from directories.
+func (c *Config) sectionsFromDirs() bool {
+	return c.Sections == SectionsFromDirs
+}
+
+// permalink returns the permalink pattern for pages in the section sec, which
+// is the pattern of the closest section with one.
+func (c *Config) permalink(sec string) string {
+	for {
+		if p, ok := c.Permalinks[sec]; ok {
+			return p
+		}
+		if sec == "" || sec == "." || sec == "/" {
+			return ""
+		}
+		sec = path.Dir(sec)
+	}
+}
+
+// checkPermalink returns an error if pattern is not a valid permalink pattern.
+func checkPermalink(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("%s must start with /", pattern)
+	}
+	for _, part := range strings.Split(pattern, "/") {
+		if !strings.HasPrefix(part, ":") {
+			continue
+		}
+		ok := false
+		for _, tok := range permalinkTokens {
+			if part == tok {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Errorf("unknown placeholder %s in %s", part, pattern)
+		}
+	}
+	return nil
+}
+
+// expandPermalink returns the url of p, given by the permalink pattern.
+func expandPermalink(pattern string, p *Page) string {
+	date := p.date()
+	name := p.fileName()
+	slug := name
+	if data, ok := p.Data.(map[string]interface{}); ok {
+		if s, ok := data[pageSlug].(string); ok && s != "" {
+			slug = s
+		}
+	}
+	r := strings.NewReplacer(
+		":section", p.sectionName(),
+		":slug", slug,
+		":filename", name,
+		":title", slugify(p.Title()),
+		":year", date.Format("2006"),
+		":month", date.Format("01"),
+		":day", date.Format("02"),
+	)
+	u := path.Clean(r.Replace(pattern))
+	if strings.HasSuffix(pattern, "/") && u != "/" {
+		u += "/"
+	}
+	return u
+}
+
+// date returns the date set in the front matter of the page, or its
+// modification time.
+func (p *Page) date() time.Time {
+	if data, ok := p.Data.(map[string]interface{}); ok {
+		switch d := data[pageDate].(type) {
+		case time.Time:
+			return d
+		case string:
+			for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+				if t, err := time.Parse(layout, d); err == nil {
+					return t
+				}
+			}
+		}
+	}
+	return p.ModTime
+}
+
+// urlFile returns the path of the file served at the url u, relative to the
+// output directory.
+func urlFile(u string) string {
+	if strings.HasSuffix(u, "/") {
+		u += indexPage
+	}
+	return filepath.FromSlash(u)
+}
@@ -0,0 +1,155 @@
+package bongo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig("testdata/sample", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Theme != "blue" || cfg.Title != "Selling alien technologies" || cfg.Output != OutputDir {
+		t.Errorf("unexpected config %v", cfg)
+	}
+	if len(cfg.Static) != 1 || cfg.Static[0] != "media" {
+		t.Errorf("expected media static dir got %v", cfg.Static)
+	}
+	if cfg.site["subtitle"] != "A dead man from Tanzania" {
+		t.Errorf("expected the raw settings for templates got %v", cfg.site)
+	}
+
+	dir, err := ioutil.TempDir("", "bongo-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg, err = LoadConfig(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Theme != defaultTheme || cfg.Summary != defaultSummaryWords {
+		t.Errorf("expected defaults got %v", cfg)
+	}
+
+	file := filepath.Join(dir, DefaultConfigFile)
+	bad := `
+theme: missing
+baseURL: example.com
+sections: folders
+permalinks:
+  blog: /:year/:nope/
+params:
+  analytics: UA-1
+`
+	if err = ioutil.WriteFile(file, []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadConfig(dir, "")
+	cerr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("expected a config error got %v", err)
+	}
+	if len(cerr.Errors) != 4 {
+		t.Errorf("expected 4 errors got %v", cerr.Errors)
+	}
+
+	if err = ioutil.WriteFile(file, []byte("theme: [a, b]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadConfig(dir, "")
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected an error with the line got %v", err)
+	}
+}
+
+func TestConfigEnvironment(t *testing.T) {
+	dir := writeProject(t, map[string]string{
+		DefaultConfigFile:           "baseURL: /\nparams:\n  analytics_id: UA-1\n  author: me\n",
+		EnvConfigFile("production"): "baseURL: https://example.com\nparams:\n  analytics_id: UA-2\n",
+	})
+	defer os.RemoveAll(dir)
+	os.Setenv("BONGO_PARAMS_ANALYTICS_ID", "UA-3")
+	os.Setenv("BONGO_ANCHORS", "true")
+	defer os.Unsetenv("BONGO_PARAMS_ANALYTICS_ID")
+	defer os.Unsetenv("BONGO_ANCHORS")
+
+	cfg, err := LoadConfig(dir, "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BaseURL != "https://example.com" || !cfg.Anchors {
+		t.Errorf("unexpected config %v", cfg)
+	}
+	if cfg.Params["analytics_id"] != "UA-3" || cfg.Params["author"] != "me" {
+		t.Errorf("unexpected params %v", cfg.Params)
+	}
+	sources := make(map[string]string)
+	for _, s := range cfg.Settings() {
+		sources[s.Key] = s.Source
+	}
+	expect := map[string]string{
+		"baseURL":             EnvConfigFile("production"),
+		"params.analytics_id": "BONGO_PARAMS_ANALYTICS_ID",
+		"params.author":       DefaultConfigFile,
+		"anchors":             "BONGO_ANCHORS",
+		"theme":               "default",
+	}
+	for k, v := range expect {
+		if sources[k] != v {
+			t.Errorf("expected %s to be set in %s got %s", k, v, sources[k])
+		}
+	}
+
+	os.Setenv("BONGO_SUMMARY", "many")
+	defer os.Unsetenv("BONGO_SUMMARY")
+	if _, err = LoadConfig(dir, ""); err == nil || !strings.Contains(err.Error(), "BONGO_SUMMARY") {
+		t.Errorf("expected an error for BONGO_SUMMARY got %v", err)
+	}
+}
+
+func TestPermalink(t *testing.T) {
+	cfg := &Config{Permalinks: map[string]string{"blog": "/:section/:year/:month/:slug/"}}
+	p := &Page{
+		Path:    "site/hello.md",
+		ModTime: time.Date(2015, 6, 2, 0, 0, 0, 0, time.UTC),
+		Data:    map[string]interface{}{"section": "blog/golang", "slug": "hi"},
+	}
+	p.permalink = cfg.permalink(p.sectionName())
+	if u := p.URL(); u != "/blog/golang/2015/06/hi/" {
+		t.Errorf("unexpected url %s", u)
+	}
+	if f := urlFile(p.URL()); f != filepath.FromSlash("/blog/golang/2015/06/hi/index.html") {
+		t.Errorf("unexpected file %s", f)
+	}
+	if cfg.permalink("docs") != "" {
+		t.Error("expected no permalink for docs")
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	ctx, err := newRenderContext("", &Config{BaseURL: "https://example.com/docs/"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Page{Path: "hello.md", Data: map[string]interface{}{"section": "blog"}, ctx: ctx}
+	if u := p.Permalink(); u != "https://example.com/docs/blog/hello.html" {
+		t.Errorf("unexpected permalink %s", u)
+	}
+	abs := templateFuncs(nil, nil, nil, "/docs")["absURL"].(func(string) string)
+	sample := map[string]string{
+		"/css/style.css":          "/docs/css/style.css",
+		"css/style.css":           "/docs/css/style.css",
+		"https://golang.org/doc/": "https://golang.org/doc/",
+	}
+	for k, v := range sample {
+		if u := abs(k); u != v {
+			t.Errorf("expected %s got %s", v, u)
+		}
+	}
+}
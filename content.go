@@ -0,0 +1,339 @@
+package bongo
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/a8m/mark"
+	nethtml "golang.org/x/net/html"
+)
+
+const (
+	//AnchorsKey is the configuration key which adds anchor links to headings when
+	// set to true
+	AnchorsKey = "anchors"
+
+	//SummaryKey is the configuration key for the number of words in automatic
+	// summaries
+	SummaryKey = "summary"
+
+	//MoreMarker separates the summary from the rest of the content
+	MoreMarker = "<!--more-->"
+
+	//WordsPerMinute is the reading speed used to compute reading time
+	WordsPerMinute = 200
+
+	defaultSummaryWords = 70
+)
+
+type (
+	// renderContext holds site wide settings used when rendering page
+	// content.
+	renderContext struct {
+		root         string
+		links        *linkResolver
+		highlight    *highlighter
+		shortcodes   *shortcodeSet
+		images       *imageSet
+		anchors      bool
+		summaryWords int
+		baseURL      string
+	}
+
+	// pageContent is the result of rendering the page body.
+	pageContent struct {
+		html    string
+		plain   string
+		toc     *TableOfContents
+		words   int
+		summary template.HTML
+	}
+
+	//Heading is an entry in the table of contents.
+	Heading struct {
+		Level    int
+		ID       string
+		Title    string
+		Children []*Heading
+	}
+
+	//TableOfContents lists the headings of a page.
+	TableOfContents struct {
+		// Headings are nested by level, a h3 following a h2 is a child of the h2.
+		Headings []*Heading
+
+		// HTML is the headings rendered as nested lists of links.
+		HTML template.HTML
+	}
+)
+
+func newRenderContext(root string, cfg *Config, links *linkResolver) (*renderContext, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	h, err := newHighlighter(cfg.Highlight)
+	if err != nil {
+		return nil, err
+	}
+	ctx := &renderContext{root: root, links: links, highlight: h, anchors: cfg.Anchors, summaryWords: defaultSummaryWords, baseURL: cfg.BaseURL}
+	if cfg.Summary > 0 {
+		ctx.summaryWords = cfg.Summary
+	}
+	return ctx, nil
+}
+
+//TableOfContents returns the headings of the page.
+func (p *Page) TableOfContents() *TableOfContents {
+	return p.content().toc
+}
+
+//WordCount returns the number of words in the page content.
+func (p *Page) WordCount() int {
+	return p.content().words
+}
+
+//ReadingTime returns the estimated time in minutes needed to read the page.
+func (p *Page) ReadingTime() int {
+	return (p.WordCount() + WordsPerMinute - 1) / WordsPerMinute
+}
+
+//Summary returns the content before the <!--more--> marker, or the first words
+// of the page when there is no marker.
+func (p *Page) Summary() template.HTML {
+	return p.content().summary
+}
+
+//RawContent returns the markdown source of the page, without the front matter.
+func (p *Page) RawContent() string {
+	return string(p.Body)
+}
+
+//Plain returns the text of the rendered page, without html tags.
+func (p *Page) Plain() string {
+	return p.content().plain
+}
+
+// content renders the page the first time it is called, and returns the same
+// result on subsequent calls.
+func (p *Page) content() *pageContent {
+	p.once.Do(func() {
+		p.out = p.render()
+	})
+	return p.out
+}
+
+// resetContent drops the rendered content, so that it is rendered again with
+// the current body and render context.
+func (p *Page) resetContent() {
+	p.once = sync.Once{}
+	p.out = nil
+	p.errs = nil
+	p.unresolved = nil
+}
+
+// render renders the page body, collecting the headings for the table of
+// contents as they are rendered. Shortcodes are expanded before the markdown
+// is rendered.
+func (p *Page) render() *pageContent {
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = &renderContext{summaryWords: defaultSummaryWords}
+	}
+	sc := &shortcodeExpander{page: p, ctx: ctx}
+	src, err := sc.expand(string(p.Body))
+	if err != nil {
+		p.errs = append(p.errs, err)
+		src = string(p.Body)
+	}
+	var headings []*Heading
+	ids := make(map[string]int)
+	m := mark.New(extractFences(src), mark.DefaultOptions())
+	m.AddRenderFn(mark.NodeHeading, func(node mark.Node) string {
+		h := node.(*mark.HeadingNode)
+		var inner string
+		for _, n := range h.Nodes {
+			inner += n.Render()
+		}
+		title := plainText(inner)
+		id := uniqueID(ids, slugify(title))
+		headings = append(headings, &Heading{Level: h.Level, ID: id, Title: title})
+		if ctx.anchors {
+			// the anchor is empty so that it doesn't end up in summaries, themes
+			// can style it with the anchor class.
+			inner = fmt.Sprintf(`<a class="anchor" href="#%s"></a>`, id) + inner
+		}
+		return fmt.Sprintf(`<h%d id="%s">%s</h%d>`, h.Level, id, inner, h.Level)
+	})
+	m.AddRenderFn(mark.NodeCode, func(node mark.Node) string {
+		c := node.(*mark.CodeNode)
+		opts := parseFence(c.Lang)
+		c.Lang = opts.lang
+		if ctx.highlight == nil || opts.lang == "" {
+			return c.Render()
+		}
+		out, err := ctx.highlight.render(c.Text, opts)
+		if err != nil {
+			return c.Render()
+		}
+		return out
+	})
+	out := sc.restore(m.Render())
+	if ctx.links != nil {
+		out = ctx.links.rewrite(p, out)
+	}
+	text := plainText(out)
+	c := &pageContent{
+		html:  out,
+		plain: text,
+		toc:   newTableOfContents(headings),
+		words: countWords(text),
+	}
+	if i := strings.Index(out, MoreMarker); i >= 0 {
+		c.summary = template.HTML(closeTags(out[:i]))
+	} else {
+		c.summary = template.HTML(html.EscapeString(truncateWords(text, ctx.summaryWords)))
+	}
+	return c
+}
+
+func newTableOfContents(headings []*Heading) *TableOfContents {
+	toc := &TableOfContents{}
+	var stack []*Heading
+	for _, h := range headings {
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			toc.Headings = append(toc.Headings, h)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, h)
+		}
+		stack = append(stack, h)
+	}
+	buf := &bytes.Buffer{}
+	writeHeadings(buf, toc.Headings)
+	toc.HTML = template.HTML(buf.String())
+	return toc
+}
+
+func writeHeadings(buf *bytes.Buffer, headings []*Heading) {
+	if len(headings) == 0 {
+		return
+	}
+	buf.WriteString("<ul>")
+	for _, h := range headings {
+		fmt.Fprintf(buf, `<li><a href="#%s">%s</a>`, h.ID, html.EscapeString(h.Title))
+		writeHeadings(buf, h.Children)
+		buf.WriteString("</li>")
+	}
+	buf.WriteString("</ul>")
+}
+
+// plainText returns the text in the html fragment src, without the tags.
+func plainText(src string) string {
+	buf := &bytes.Buffer{}
+	z := nethtml.NewTokenizer(strings.NewReader(src))
+	for {
+		switch z.Next() {
+		case nethtml.ErrorToken:
+			return strings.TrimSpace(buf.String())
+		case nethtml.TextToken:
+			buf.Write(z.Text())
+		case nethtml.StartTagToken, nethtml.EndTagToken, nethtml.SelfClosingTagToken:
+			// keep words in adjacent elements apart.
+			buf.WriteByte(' ')
+		}
+	}
+}
+
+// voidElements have no end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// closeTags returns the html fragment src with the end tags of the elements
+// left open in it, so that a fragment cut in the middle of a paragraph is
+// balanced.
+func closeTags(src string) string {
+	var open []string
+	z := nethtml.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == nethtml.ErrorToken {
+			break
+		}
+		name, _ := z.TagName()
+		switch {
+		case tt == nethtml.StartTagToken && !voidElements[string(name)]:
+			open = append(open, string(name))
+		case tt == nethtml.EndTagToken:
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == string(name) {
+					open = open[:i]
+					break
+				}
+			}
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		src += "</" + open[i] + ">"
+	}
+	return src
+}
+
+// slugify turns s into a string usable as an element id.
+func slugify(s string) string {
+	buf := &bytes.Buffer{}
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && buf.Len() > 0 {
+				buf.WriteByte('-')
+			}
+			buf.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	if buf.Len() == 0 {
+		return "section"
+	}
+	return buf.String()
+}
+
+// uniqueID returns id, with a numeric suffix if it was already used.
+func uniqueID(ids map[string]int, id string) string {
+	n, ok := ids[id]
+	ids[id] = n + 1
+	if !ok {
+		return id
+	}
+	return uniqueID(ids, fmt.Sprintf("%s-%d", id, n))
+}
+
+// countWords counts the words in s, ignoring punctuation like heading anchors.
+func countWords(s string) int {
+	n := 0
+	for _, w := range strings.Fields(s) {
+		if strings.IndexFunc(w, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func truncateWords(s string, n int) string {
+	words := strings.Fields(s)
+	if len(words) <= n {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:n], " ") + "…"
+}
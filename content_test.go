@@ -0,0 +1,116 @@
+package bongo
+
+import (
+	"strings"
+	"testing"
+)
+
+var tocPost = `# Install
+
+Get the binary.
+
+## Linux
+
+Download it.
+
+## Linux
+
+<!--more-->
+
+# Usage
+
+Run it every day.
+`
+
+func TestPageContent(t *testing.T) {
+	p := &Page{Body: []byte(tocPost)}
+	ctx, err := newRenderContext("", &Config{Anchors: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ctx = ctx
+
+	out := string(p.HTML())
+	for _, id := range []string{`id="install"`, `id="linux"`, `id="linux-1"`, `id="usage"`, `href="#linux-1"`} {
+		if !strings.Contains(out, id) {
+			t.Errorf("expected %s in %s", id, out)
+		}
+	}
+	toc := p.TableOfContents()
+	if len(toc.Headings) != 2 {
+		t.Fatalf("expected 2 top level headings got %d", len(toc.Headings))
+	}
+	install := toc.Headings[0]
+	if install.Title != "Install" || len(install.Children) != 2 || install.Children[1].ID != "linux-1" {
+		t.Errorf("unexpected headings %v", install)
+	}
+	if !strings.HasPrefix(string(toc.HTML), `<ul><li><a href="#install">Install</a><ul>`) {
+		t.Errorf("unexpected toc html %s", toc.HTML)
+	}
+	if p.WordCount() != 13 {
+		t.Errorf("expected 13 words got %d", p.WordCount())
+	}
+	if p.ReadingTime() != 1 {
+		t.Errorf("expected 1 minute got %d", p.ReadingTime())
+	}
+	summary := string(p.Summary())
+	if !strings.Contains(summary, "Download it.") || strings.Contains(summary, "Usage") {
+		t.Errorf("unexpected summary %s", summary)
+	}
+
+	p = &Page{Body: []byte("one two three four")}
+	p.ctx, _ = newRenderContext("", &Config{Summary: 2}, nil)
+	if s := p.Summary(); s != "one two…" {
+		t.Errorf("expected truncated summary got %s", s)
+	}
+}
+
+func TestCloseTags(t *testing.T) {
+	sample := map[string]string{
+		"<p>one</p>":                 "<p>one</p>",
+		"<p>one <code>two":           "<p>one <code>two</code></p>",
+		"<p>one</p><ul><li>two<br>":  "<p>one</p><ul><li>two<br></li></ul>",
+		"<div><p>one</p><p>two <em>": "<div><p>one</p><p>two <em></em></p></div>",
+	}
+	for k, v := range sample {
+		if s := closeTags(k); s != v {
+			t.Errorf("expected %s got %s", v, s)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	sample := map[string]string{
+		"Hello World":        "hello-world",
+		"  The `go` tool!  ": "the-go-tool",
+		"Habari za asubuhi?": "habari-za-asubuhi",
+		"???":                "section",
+	}
+	for k, v := range sample {
+		if s := slugify(k); s != v {
+			t.Errorf("%s: expected %s got %s", k, v, s)
+		}
+	}
+}
+
+func TestPageRenderOnce(t *testing.T) {
+	p := &Page{Body: []byte("Hello *world*")}
+	done := make(chan string)
+	for i := 0; i < 4; i++ {
+		go func() {
+			done <- string(p.HTML())
+		}()
+	}
+	first := <-done
+	for i := 0; i < 3; i++ {
+		if out := <-done; out != first || out == "" {
+			t.Errorf("expected %s got %s", first, out)
+		}
+	}
+	if p.RawContent() != "Hello *world*" {
+		t.Errorf("unexpected raw content %s", p.RawContent())
+	}
+	if strings.Contains(p.Plain(), "<") {
+		t.Errorf("expected plain text got %s", p.Plain())
+	}
+}
@@ -0,0 +1,165 @@
+package bongo
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	//DataDir is the directory at the project root with data files for templates
+	DataDir = "_data"
+
+	//DataKey is the key used to store the content of the data files in the template
+	// context
+	DataKey = "Data"
+)
+
+var dataExtensions = []string{".yml", ".yaml", ".json", ".toml", ".csv"}
+
+// LoadData loads the data files in dir. The content of every file is stored
+// under its name without extension, nested by subdirectory, so the file
+// team/members.yml in dir is found at data["team"]["members"].
+//
+// YAML, JSON and TOML files are decoded as they are. The first row of CSV files
+// is the header, and the other rows become maps with the header as keys.
+func LoadData(dir string) (map[string]interface{}, error) {
+	data := make(map[string]interface{})
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !HasExt(path, dataExtensions...) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		v, err := loadDataFile(path)
+		if err != nil {
+			return fmt.Errorf("loading %s %v", path, err)
+		}
+		keys := strings.Split(filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel))), "/")
+		return setData(data, keys, v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func loadDataFile(file string) (interface{}, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yml", ".yaml":
+		err = yaml.Unmarshal(b, &v)
+	case ".json":
+		err = json.Unmarshal(b, &v)
+	case ".toml":
+		m := make(map[string]interface{})
+		_, err = toml.Decode(string(b), &m)
+		v = m
+	case ".csv":
+		v, err = csvRecords(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return normalize(v), nil
+}
+
+func csvRecords(b []byte) ([]map[string]string, error) {
+	rows, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	header := rows[0]
+	var rst []map[string]string
+	for _, row := range rows[1:] {
+		rec := make(map[string]string)
+		for i, v := range row {
+			if i < len(header) {
+				rec[header[i]] = v
+			}
+		}
+		rst = append(rst, rec)
+	}
+	return rst, nil
+}
+
+// setData stores v in data under the nested keys.
+func setData(data map[string]interface{}, keys []string, v interface{}) error {
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := data[k].(map[string]interface{})
+		if !ok {
+			if _, exists := data[k]; exists {
+				return fmt.Errorf("data %s is both a file and a directory", k)
+			}
+			next = make(map[string]interface{})
+			data[k] = next
+		}
+		data = next
+	}
+	last := keys[len(keys)-1]
+	if old, ok := data[last].(map[string]interface{}); ok {
+		m, isMap := v.(map[string]interface{})
+		if !isMap {
+			return fmt.Errorf("data %s is both a file and a directory", last)
+		}
+		for k, val := range m {
+			old[k] = val
+		}
+		return nil
+	}
+	data[last] = v
+	return nil
+}
+
+// normalize converts the maps decoded from yaml to maps with string keys, so
+// that all data files look the same to templates.
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{})
+		for k, val := range x {
+			m[fmt.Sprint(k)] = normalize(val)
+		}
+		return m
+	case map[string]interface{}:
+		for k, val := range x {
+			x[k] = normalize(val)
+		}
+		return x
+	case []interface{}:
+		for i, val := range x {
+			x[i] = normalize(val)
+		}
+		return x
+	}
+	return v
+}
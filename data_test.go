@@ -0,0 +1,36 @@
+package bongo
+
+import "testing"
+
+func TestLoadData(t *testing.T) {
+	data, err := LoadData("testdata/sample/_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	team, ok := data["team"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected team to be nested got %v", data["team"])
+	}
+	members, ok := team["members"].([]interface{})
+	if !ok || len(members) != 2 {
+		t.Fatalf("expected 2 members got %v", team["members"])
+	}
+	if m, ok := members[0].(map[string]interface{}); !ok || m["role"] != "maintainer" {
+		t.Errorf("expected members with string keys got %#v", members[0])
+	}
+	releases, ok := data["releases"].([]map[string]string)
+	if !ok || len(releases) != 2 || releases[1]["notes"] != "serve command" {
+		t.Errorf("unexpected releases %v", data["releases"])
+	}
+	if links, ok := data["links"].(map[string]interface{}); !ok || links["github"] != "https://github.com/gernest/bongo" {
+		t.Errorf("unexpected links %v", data["links"])
+	}
+	if build, ok := data["build"].(map[string]interface{}); !ok || build["go"].(map[string]interface{})["version"] != "1.7" {
+		t.Errorf("unexpected build %v", data["build"])
+	}
+
+	data, err = LoadData("testdata/missing")
+	if err != nil || len(data) != 0 {
+		t.Errorf("expected empty data for missing directory got %v %v", data, err)
+	}
+}
@@ -39,8 +39,24 @@ The project will be rebuilt if any markdown file changes.
 
 	bongo serve
 
+While serving, the pages can be searched at http://localhost:8000/_bongo/search?q=your+words
+which returns the matching pages as json, best matches first. Words match their other
+forms, like install and installing, words in double quotes match as a phrase, and matches
+in titles and headings rank higher. The index is updated when the project is rebuilt.
 
-The generated website will be in the directory _site at the root of your foo project.
+
+The generated website will be in the directory _site at the root of your foo project,
+unless you set the output setting, or pass another directory with --destination.
+
+	bongo build --destination /var/www/foo
+
+To check the generated website for broken links and missing anchors.
+
+	bongo check --source path/to/foo
+
+Or pass --check to bongo build to check right after building. External links are
+skipped, unless you pass --external, in which case they must match a prefix listed
+in the file given by --allow.
 
 
 The Website Project Structure
@@ -70,11 +86,173 @@ The configuration is in yaml format. And there are a few settings you can change
 	subtitle
 	  The string representing subtitle of the project
 
+	baseURL
+	  The url the site is published at, like https://example.com or /docs. Templates
+	  get the absolute url of a page with .Page.Permalink, and absURL puts it before
+	  any url of the site, like {{absURL "/css/style.css"}}.
+
+	output
+	  The directory the site is generated in. Defaults to _site. Markdown files in it are
+	  not processed. Bongo writes a .bongo file in it, and only removes the files of a
+	  directory which has one, or of _site; the build fails when another directory
+	  has files but no .bongo file.
+
+	fileMode, dirMode
+	  The permissions of the generated files and directories. Default to 0644 and 0755.
+
+	permalinks
+	  The url of the pages in a section, as a pattern with the placeholders :section,
+	  :slug(the slug frontmatter or the file name), :filename, :title, :year, :month and
+	  :day(from the date frontmatter or the modification time). The pattern of a section
+	  is used by the sections nested in it. For instance
+
+		permalinks:
+		  blog: /:year/:month/:slug/
+
+	minify
+	  Set it to true to minify the generated pages, with the css and javascript in them.
+	  The content of pre elements, like code blocks, is kept as it is. You can also pass
+	  --minify to bongo build.
+
+	images
+	  The settings of image processing: widths of the srcset variants(480, 800 and 1200 by
+	  default), the quality of JPEG images(75) and the cache directory(_cache/images).
+
+	search
+	  The search index, a json file with the title, url, section, tags, headings and text
+	  of every page, which client side search libraries like lunr.js and Fuse.js can load.
+	  It is only written when enable is true, to search.json, or the file setting, in the
+	  directory of every language. words limits the text to the first words of the page.
+	  Pages with search: false in their frontmatter are left out.
+
+	languages, defaultLanguage
+	  The languages of a multilingual site, with their name and weight. For instance
+
+		languages:
+		  en:
+		    name: English
+		    weight: 1
+		  sw:
+		    name: Kiswahili
+		    weight: 2
+
+	  defaultLanguage defaults to the language with the lowest weight. See Languages below.
+
+	params
+	  Your own settings, which are available to the templates as .Params.
+
+	plugins
+	  External plugins, which are programs in any language run at the steps of the build
+	  they list in hooks. For instance
+
+		plugins:
+		  - name: spelling
+		    command: ./tools/spelling.py
+		    args: [--lang, en]
+		    hooks: [OnPagesLoaded, OnPostRender]
+		    timeout: 10s
+
+	  See The Library below for the hooks. The program runs in the project root, once for
+	  every call of a hook. It reads a json request from stdin, with the hook and the
+	  pages, page or file of the hook, and writes a json response to stdout, which can
+	  change pages and files, add new pages and report diagnostics. See PluginRequest and
+	  PluginResponse for the fields. A plugin which fails, runs longer than its timeout
+	  (30s by default) or reports a diagnostic with the error level stops the build, and
+	  the error tells the plugin, the hook and the page.
+
+	  A plugin can also be a WebAssembly module, set module instead of command. Modules
+	  run sandboxed in the build, without access to files or the network, so they are
+	  safe for transforms you don't trust. The memory of a module is limited to the
+	  memory setting in MiB (16 by default)
+
+		plugins:
+		  - name: typography
+		    module: ./plugins/typography.wasm
+		    memory: 32
+		    timeout: 2s
+
+	  The module exports its memory and bongo_alloc(size i32) i32, which returns where
+	  the input of size bytes is written. It exports one or both of the transforms
+
+		bongo_markdown(ptr i32, len i32) i64   the markdown of every page, before render
+		bongo_html(ptr i32, len i32) i64       every html file, after render
+
+	  They return the address of the output in the high 32 bits and its length in the
+	  low 32 bits, or -1 when they fail. Every call gets a new instance of the module,
+	  which must return before the timeout. What the module writes to stderr is added
+	  to its errors.
+
 	theme
 	  The name of the theme to use. Note that, bongo comes with a default theme called gh.
 	  Only if you have a theme installed in the _themes directory at the root of your project
 	  will you neeed to specify this.
 
+	anchors
+	  Set it to true to add an empty link with the anchor class to every heading, which
+	  themes can style to link to the heading.
+
+	summary
+	  The number of words in automatic summaries of posts. Defaults to 70.
+
+	sections
+	  Set it to directories to take the section of a post from the directory it is in,
+	  instead of the section frontmatter(the default, frontmatter). For instance
+	  docs/guide/install.md will be in the docs/guide section, which is nested in the
+	  docs section.
+
+	highlight
+	  Settings for syntax highlighting of fenced code blocks. style is the name of the
+	  highlight style(defaults to github), classes uses css classes instead of inline
+	  styles and linenos adds line numbers to all code blocks. Set disable to true to
+	  turn highlighting off. When using classes, you can generate the stylesheet with
+
+		bongo gen css --style monokai > static/css/highlight.css
+
+	menus
+	  Named navigation menus, every entry has a name, url, weight and optionally the
+	  identifier of its parent entry. For instance
+
+		menus:
+		  main:
+		    - name: Docs
+		      url: /docs/
+		      weight: 1
+
+
+You can have different settings for every environment you build the site for, in
+_bongo.<environment>.yml files. For instance _bongo.production.yml is read on top of
+_bongo.yml when building with
+
+	bongo build --environment production
+
+or when the BONGO_ENV environment variable is production. Settings in both files are
+merged, so you only need to write the ones which are different. Environment variables
+starting with BONGO_ override single settings, for instance BONGO_BASEURL sets baseURL
+and BONGO_PARAMS_ANALYTICS sets analytics in params. To see the settings, and where each
+one was set
+
+	bongo config --environment production
+
+Settings which bongo doesn't know are reported as warnings, so put your own settings
+in params. The build stops with a list of the problems when a setting is not valid, for
+instance when the theme is not installed or a static directory doesn't exist.
+
+Pages can also be generated from the data files, without markdown files, with a
+_content.yml file at the root of your project. It is a list of entries which make a page
+for every item of a list in the data files. For instance with _data/releases.yml
+
+	- data: releases
+	  path: releases/{{.version}}.md
+	  content: "{{.notes}}"
+	  front:
+	    title: Release {{.version}}
+	    section: releases
+
+Path, content and the strings in front are templates, executed with the item. The front
+matter of a page are the fields of its item and front. The path is where the markdown
+file of the page would be, it sets the url of the page, and it can't be the path of another
+page. Generated pages are in sections, menus and search like any other page.
+
 
 Themes
 
@@ -93,7 +271,6 @@ the following templates.
 	post.html
 		- used to render the posts
 
-
 These templates can be used in project, by setting the view value of frontmatter. For instance
 if I set view to post, then post.html will be used on that particular file.
 
@@ -103,6 +280,85 @@ theme. They will be copied to the output directory unchanged.
 All themes custom themes should live under the _theme directory at the project root. Please
 see testdata/sample/_themes for an example.
 
+Templates can process css, javascript and svg files with the asset pipeline. asset returns
+a file, bundle joins files into one, minify removes whitespace and comments, and fingerprint
+puts a hash of the content in the file name, so that browsers can cache it forever. For
+instance
+
+	{{$css := bundle "css/site.css" "css/reset.css" "css/style.css" | minify | fingerprint}}
+	<link rel="stylesheet" href="{{$css.URL}}" integrity="{{$css.Integrity}}">
+
+The files are looked up in the _assets directory at the project root, then in the theme
+directory and then in the project root. They are written to the output directory when
+their URL is used, and take the place of static files with the same name.
+
+Images in the project are processed with the image function. Resize, Fit, Fill and Crop
+take the size like 800x600, which Resize also takes as 800x to keep the aspect ratio,
+followed by optional anchor (center, topleft, bottom...), format(jpg, png or gif) and
+quality(q90) options. For instance
+
+	{{with image "media/photo.jpg"}}
+		{{$thumb := .Fill "300x200 top"}}
+		<img src="{{$thumb.URL}}" srcset="{{.Srcset}}" width="{{$thumb.Width}}" height="{{$thumb.Height}}">
+	{{end}}
+
+Processed images are kept in the cache directory, so that they are only processed again
+when the source image or the options change.
+
+Data files in the _data directory at the project root are available to all templates as
+.Data. YAML, JSON, TOML and CSV files are supported, and they are stored under their name
+nested by directory. For instance _data/team/members.yml is .Data.team.members. The rows
+of a CSV file are maps with the first row of the file as keys.
+
+All templates have the settings of _bongo.yml as .Site, and the params setting as .Params.
+
+The templates have access to the current section as .Section, which has Parent, Children,
+Pages, Ancestors and Index(the _index.md page) fields. When sections are directories, an
+index.md file at the project root is the home page, and home.html gets it as .Page.
+
+Menus are available as .Menus, sorted by weight. Each entry has IsActive and HasActive
+methods which take the current page, for instance
+
+	{{range .Menus.main}}
+		<a href="{{.URL}}" {{if .IsActive $.Page}}class="active"{{end}}>{{.Name}}</a>
+	{{end}}
+
+And every page has Breadcrumbs, which are links to the sections the page is in.
+
+Besides .Page.HTML, pages have a TableOfContents with the nested Headings and a pre
+rendered HTML list, WordCount, ReadingTime in minutes and a Summary. The summary is the
+content before a <!--more--> line, or the first words of the post when there is none.
+Every heading gets a unique id, derived from its text.
+
+The content is rendered once, and .Page.HTML can be used any number of times, in any
+template. .Page.RawContent is the markdown source and .Page.Plain is the rendered text
+without html tags.
+
+Pages are sorted by the date in their frontmatter, or their modification time, and then
+by path. Every page links to the pages before and after it in its section with
+.Page.PrevInSection and .Page.NextInSection, and in the whole site with .Page.Prev and
+.Page.Next. They are nil for the first and last pages.
+
+
+Languages
+
+The language of a post is the language code before the extension of its file, like
+post.sw.md, or the name of a directory it is in, like content/sw/post.md. Posts without
+one are in the default language. Every language has its own sections, menus and home
+page, and the pages of languages other than the default one are generated in a
+directory named after the language, for instance /sw/blog/post.html.
+
+Posts with the same path without the language, or the same translationKey frontmatter,
+are translations of each other. .Page.Translations lists them, and .Page.Lang is the
+language code. The templates have the current language as .Language and all of them
+as .Languages.
+
+Translated strings for templates are in files named after the language in the _i18n
+directory, like _i18n/sw.yml. The i18n function looks them up, in the default language
+when they are missing, and formats them with the other arguments, for instance
+
+	{{i18n "read_more" .Page.Title}}
+	{{i18n "nav.home"}}
 
 Frontmatter
 
@@ -135,9 +391,82 @@ Important frontmatter settings,
 
 		The default section is home.
 
+		Every element of the section path is a section on its own, so blog/golang is
+		nested in blog, and each one gets its own index page. A file named _index.md
+		is not rendered as a post, its frontmatter and content describe the section
+		it is in instead.
+
+		A file named index.md makes its directory a page bundle. The page is generated
+		as index.html in a directory named after the bundle, like blog/trip/, and the
+		other files of the bundle are copied next to it. So images and attachments of
+		the post live with it, and relative links like ![](map.png) work. The files are
+		available to templates as .Page.Resources, for instance
+
+			{{range .Page.Resources.Match "*.png"}}<img src="{{.URL}}">{{end}}
+
 	view
 		- specifies the template to render the content.Defaults to post.
 
+	menu
+		- the name of the menu(or a list of names) to add the post to. The entry is
+		ordered by the weight frontmatter, and nested under the entry whose identifier
+		or name matches the parent frontmatter.
+
+
+Links
+
+You can link to other posts by their markdown file, relative to the current file or
+to the project root when the path starts with /. For instance
+
+	Continue with [chapter two](1.2.md#installing)
+
+will point to the generated page of 1.2.md, keeping the #installing fragment. Links
+to markdown files which are not part of the project are reported as warnings, or
+errors when building with the --strict flag.
+
+
+Fenced code blocks with a language are highlighted. Options can be given after the
+language, linenos adds line numbers, linenostart sets the number of the first line and
+hl_lines highlights lines, for instance
+
+	```go linenos hl_lines=2,4-6
+
+
+Shortcodes
+
+Shortcodes embed html in posts without writing it in markdown. They look like this
+
+	{{< figure src="/media/logo.png" caption="Our logo" >}}
+
+	{{< note warning >}}
+	Markdown **content** of the note.
+	{{< /note >}}
+
+Arguments are either named like src="/media/logo.png", or positional like warning. A
+shortcode is rendered by the template shortcodes/NAME.html in the theme directory, which
+gets the arguments with .Get "src" or .Get 0, and the content between paired tags as
+.Inner. Bongo comes with the following shortcodes, which themes can override.
+
+	figure
+		- an image with src, alt, title, width, sizes, class and caption arguments. Local
+		images get a srcset with smaller variants, and their width and height.
+
+	note
+		- a callout with the type given as the first argument, or as type.
+
+	include
+		- the content of a file relative to the project root. Markdown files are rendered,
+		other files are highlighted as code. lines="10-40" includes a range of lines, and
+		region="name" the lines between comments with region: name and endregion: name.
+		When serving, the page is rebuilt when the included file changes.
+
+	highlight
+		- highlights the content, the first argument is the language and the second are
+		options like "linenos hl_lines=2".
+
+To write a shortcode without expanding it, put its name and arguments in a /* comment,
+right inside the {{< and >}} delimiters. Errors in shortcodes fail the build, and tell
+the line in the markdown file where the shortcode is.
 
 
 The Library
@@ -151,5 +480,25 @@ own static website generator with your own rules.
 I challenge you, to try implementing different Generators. Or, implement different components of the
 generator interface. I have default implementations shipped with bongo.
 
+To add a feature without replacing the Generator, write a Plugin and register it on the App.
+Plugins have hooks which run at every step of the build, they can change the configuration,
+add or remove pages, see every page before it is rendered and every file before it is written.
+Embed PluginBase to implement only the hooks you need.
+
+	type stamp struct{ bongo.PluginBase }
+
+	func (stamp) Name() string { return "stamp" }
+
+	func (stamp) OnPostRender(path string, b []byte) ([]byte, error) {
+		return append(b, "<!-- built with bongo -->"...), nil
+	}
+
+	app := bongo.New()
+	app.Use(stamp{})
+	err := app.Run("path/to/foo")
+
+Plugins which implement ContentAdapter generate pages from code. Pages gets the data files,
+and returns VirtualPages, which have the path, front matter and markdown of a page.
+
 */
 package bongo
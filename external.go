@@ -0,0 +1,385 @@
+package bongo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	//PluginsKey is the configuration key listing the external plugins
+	PluginsKey = "plugins"
+
+	//DefaultPluginTimeout is how long an external plugin can run for a single
+	// hook, when no timeout is configured.
+	DefaultPluginTimeout = 30 * time.Second
+
+	hookConfig       = "OnConfig"
+	hookPagesLoaded  = "OnPagesLoaded"
+	hookPageRender   = "OnPageRender"
+	hookPostRender   = "OnPostRender"
+	hookBuildDone    = "OnBuildDone"
+	diagnosticsError = "error"
+)
+
+var pluginHooks = []string{hookConfig, hookPagesLoaded, hookPageRender, hookPostRender, hookBuildDone}
+
+type (
+	//PluginConfig is an external plugin in the configuration file, an
+	// executable which is run at the hooks it is listed for, like
+	//
+	//	plugins:
+	//	  - name: spelling
+	//	    command: ./tools/spelling.py
+	//	    hooks: [OnPagesLoaded]
+	//	    timeout: 10s
+	//
+	// The plugin is run once for every call of a hook, in the project root. It
+	// gets a json request on stdin, and writes a json response to stdout, see
+	// PluginRequest and PluginResponse. A plugin which exits with an error, runs
+	// longer than the timeout or reports an error diagnostic stops the build.
+	// What it writes to stderr is logged.
+	//
+	// A plugin can be a WebAssembly module instead of an executable, which is
+	// sandboxed, with memory limited to a number of MiB
+	//
+	//	plugins:
+	//	  - name: typography
+	//	    module: ./plugins/typography.wasm
+	//	    memory: 32
+	//	    timeout: 2s
+	//
+	// Its hooks are the transforms it exports, see wasmPlugin for the ABI.
+	PluginConfig struct {
+		Name    string        `yaml:"name"`
+		Command string        `yaml:"command"`
+		Args    []string      `yaml:"args"`
+		Hooks   []string      `yaml:"hooks"`
+		Timeout time.Duration `yaml:"timeout"`
+		Module  string        `yaml:"module"`
+		Memory  int           `yaml:"memory"`
+	}
+
+	//PluginRequest is the json sent to external plugins.
+	PluginRequest struct {
+		// Hook is the name of the hook, like OnPagesLoaded.
+		Hook string `json:"hook"`
+
+		// Config are the settings of the project, for OnConfig.
+		Config map[string]interface{} `json:"config,omitempty"`
+
+		// Pages are all the pages, for OnPagesLoaded and OnBuildDone.
+		Pages []*PluginPage `json:"pages,omitempty"`
+
+		// Page is the page about to be rendered, for OnPageRender.
+		Page *PluginPage `json:"page,omitempty"`
+
+		// Path and Content are the file about to be written, relative to the
+		// output directory, for OnPostRender.
+		Path    string `json:"path,omitempty"`
+		Content string `json:"content,omitempty"`
+
+		// Destination is the output directory, for OnBuildDone.
+		Destination string `json:"destination,omitempty"`
+	}
+
+	//PluginPage is a page sent to and from external plugins.
+	PluginPage struct {
+		// Path is the markdown file relative to the project root.
+		Path string `json:"path"`
+
+		// URL is known when pages are rendered.
+		URL string `json:"url,omitempty"`
+
+		// Data is the front matter and Content is the markdown body.
+		Data    map[string]interface{} `json:"data"`
+		Content string                 `json:"content"`
+
+		// Delete removes the page, in responses to OnPagesLoaded.
+		Delete bool `json:"delete,omitempty"`
+	}
+
+	//PluginResponse is the json external plugins write to stdout. All the
+	// fields are optional, an empty response changes nothing.
+	PluginResponse struct {
+		// Params are merged into the params of the configuration, for
+		// OnConfig.
+		Params map[string]interface{} `json:"params,omitempty"`
+
+		// Pages replace the pages with the same path, or are added to the
+		// site, for OnPagesLoaded. Added pages have the modification time of
+		// the configuration, content and data files.
+		Pages []*PluginPage `json:"pages,omitempty"`
+
+		// Page replaces the front matter and content of the page, for
+		// OnPageRender.
+		Page *PluginPage `json:"page,omitempty"`
+
+		// Content replaces the content of the file, for OnPostRender.
+		Content *string `json:"content,omitempty"`
+
+		Diagnostics []*Diagnostic `json:"diagnostics,omitempty"`
+	}
+
+	//Diagnostic is a problem reported by an external plugin. Errors stop the
+	// build, other levels are logged as warnings.
+	Diagnostic struct {
+		Level   string `json:"level"`
+		Page    string `json:"page,omitempty"`
+		Message string `json:"message"`
+	}
+
+	// processPlugin runs an external plugin.
+	processPlugin struct {
+		cfg  *PluginConfig
+		root string
+	}
+)
+
+// externalPlugins returns the plugins listed in the configuration of the
+// project at root. WebAssembly modules are compiled, so the plugins must be
+// closed when the build is done.
+func externalPlugins(root string, cfg *Config) (plugins, error) {
+	var rst plugins
+	for _, c := range cfg.Plugins {
+		if c.Module == "" {
+			rst = append(rst, &processPlugin{cfg: c, root: root})
+			continue
+		}
+		p, err := newWasmPlugin(root, c)
+		if err != nil {
+			rst.close()
+			return nil, fmt.Errorf("plugin %s: %v", c.Name, err)
+		}
+		rst = append(rst, p)
+	}
+	return rst, nil
+}
+
+// checkPlugin returns the problems of the plugin configuration.
+func checkPlugin(c *PluginConfig) []string {
+	var rst []string
+	if c.Name == "" {
+		rst = append(rst, "missing name")
+	}
+	switch {
+	case c.Command == "" && c.Module == "":
+		rst = append(rst, "missing command or module")
+	case c.Command != "" && c.Module != "":
+		rst = append(rst, "only one of command and module can be set")
+	case c.Command != "" && len(c.Hooks) == 0:
+		rst = append(rst, "missing hooks")
+	case c.Module != "" && len(c.Hooks) > 0:
+		rst = append(rst, "hooks can't be set for modules, they are the functions the module exports")
+	}
+	for _, h := range c.Hooks {
+		if !hasString(pluginHooks, h) {
+			rst = append(rst, fmt.Sprintf("unknown hook %s, must be one of %s", h, strings.Join(pluginHooks, ", ")))
+		}
+	}
+	if c.Timeout < 0 {
+		rst = append(rst, "timeout must not be negative")
+	}
+	if c.Memory < 0 || c.Memory > 4096 {
+		rst = append(rst, "memory must be between 0 and 4096 MiB")
+	}
+	return rst
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *processPlugin) Name() string {
+	return p.cfg.Name
+}
+
+func (p *processPlugin) OnConfig(cfg *Config) error {
+	if !hasString(p.cfg.Hooks, hookConfig) {
+		return nil
+	}
+	res, err := p.run(&PluginRequest{Hook: hookConfig, Config: cfg.site})
+	if err != nil {
+		return err
+	}
+	if len(res.Params) > 0 && cfg.Params == nil {
+		cfg.Params = make(map[string]interface{})
+	}
+	for k, v := range res.Params {
+		cfg.Params[k] = v
+	}
+	return nil
+}
+
+func (p *processPlugin) OnPagesLoaded(pages PageList) (PageList, error) {
+	if !hasString(p.cfg.Hooks, hookPagesLoaded) {
+		return pages, nil
+	}
+	req := &PluginRequest{Hook: hookPagesLoaded}
+	for _, page := range pages {
+		req.Pages = append(req.Pages, p.page(page, true))
+	}
+	res, err := p.run(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range res.Pages {
+		file, err := projectFile(p.root, v.Path)
+		if err != nil {
+			return nil, err
+		}
+		i := 0
+		for i < len(pages) && filepath.Clean(pages[i].Path) != file {
+			i++
+		}
+		switch {
+		case v.Delete && i < len(pages):
+			pages = append(pages[:i:i], pages[i+1:]...)
+		case v.Delete:
+		case i < len(pages):
+			p.update(pages[i], v)
+		default:
+			page := &Page{Path: file, ModTime: sourceModTime(p.root), virtual: true}
+			p.update(page, v)
+			pages = append(pages, page)
+		}
+	}
+	return pages, nil
+}
+
+func (p *processPlugin) OnPageRender(page *Page) error {
+	if !hasString(p.cfg.Hooks, hookPageRender) {
+		return nil
+	}
+	res, err := p.run(&PluginRequest{Hook: hookPageRender, Page: p.page(page, true)})
+	if err != nil {
+		return err
+	}
+	if res.Page != nil {
+		p.update(page, res.Page)
+	}
+	return nil
+}
+
+func (p *processPlugin) OnPostRender(file string, b []byte) ([]byte, error) {
+	if !hasString(p.cfg.Hooks, hookPostRender) {
+		return b, nil
+	}
+	res, err := p.run(&PluginRequest{Hook: hookPostRender, Path: file, Content: string(b)})
+	if err != nil {
+		return nil, err
+	}
+	if res.Content != nil {
+		return []byte(*res.Content), nil
+	}
+	return b, nil
+}
+
+func (p *processPlugin) OnBuildDone(dest string, pages PageList) error {
+	if !hasString(p.cfg.Hooks, hookBuildDone) {
+		return nil
+	}
+	req := &PluginRequest{Hook: hookBuildDone, Destination: dest}
+	for _, page := range pages {
+		req.Pages = append(req.Pages, p.page(page, false))
+	}
+	_, err := p.run(req)
+	return err
+}
+
+// page returns the page for requests, with its markdown content when content
+// is true.
+func (p *processPlugin) page(page *Page, content bool) *PluginPage {
+	rel, err := filepath.Rel(p.root, page.Path)
+	if err != nil {
+		rel = page.Path
+	}
+	rst := &PluginPage{Path: filepath.ToSlash(rel)}
+	// nested yaml front matter has maps with interface{} keys, which can't be
+	// encoded to json.
+	rst.Data, _ = normalize(page.Data).(map[string]interface{})
+	if page.sec != nil {
+		rst.URL = page.URL()
+	}
+	if content {
+		rst.Content = string(page.Body)
+	}
+	return rst
+}
+
+// update sets the front matter and content of page from the plugin.
+func (p *processPlugin) update(page *Page, v *PluginPage) {
+	if v.Data != nil {
+		page.Data = v.Data
+	}
+	if page.Data == nil {
+		page.Data = make(map[string]interface{})
+	}
+	page.Body = []byte(v.Content)
+}
+
+// run runs the plugin with the request req, and returns its response. Error
+// diagnostics in the response are returned as an error.
+func (p *processPlugin) run(req *PluginRequest) (*PluginResponse, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	timeout := p.cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultPluginTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.cfg.Command, p.cfg.Args...)
+	cmd.Dir = p.root
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+	msg := strings.TrimSpace(stderr.String())
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, fmt.Errorf("timed out after %v", timeout)
+	case err != nil && msg != "":
+		return nil, fmt.Errorf("%v: %s", err, msg)
+	case err != nil:
+		return nil, err
+	case msg != "":
+		log.Printf("plugin %s: %s\n", p.cfg.Name, msg)
+	}
+	res := &PluginResponse{}
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err = json.Unmarshal(out, res); err != nil {
+			return nil, fmt.Errorf("invalid response %v", err)
+		}
+	}
+	var errs []string
+	for _, d := range res.Diagnostics {
+		text := d.Message
+		if d.Page != "" {
+			text = d.Page + ": " + text
+		}
+		if d.Level == diagnosticsError {
+			errs = append(errs, text)
+			continue
+		}
+		log.Printf("WARNING plugin %s: %s: %s\n", p.cfg.Name, req.Hook, text)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "\n\t"))
+	}
+	return res, nil
+}
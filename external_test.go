@@ -0,0 +1,155 @@
+package bongo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const pluginModeVar = "BONGO_TEST_PLUGIN"
+
+// TestHelperPlugin is the external plugin of TestExternalPlugins, it runs when
+// the test binary is started by a build.
+func TestHelperPlugin(t *testing.T) {
+	mode := os.Getenv(pluginModeVar)
+	if mode == "" {
+		return
+	}
+	req := &PluginRequest{}
+	if err := json.NewDecoder(os.Stdin).Decode(req); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	res := &PluginResponse{}
+	switch {
+	case mode == "sleep":
+		time.Sleep(5 * time.Second)
+	case mode == "fail":
+		res.Diagnostics = []*Diagnostic{{Level: "error", Page: "hello.md", Message: "bad word"}}
+	case req.Hook == hookConfig:
+		res.Params = map[string]interface{}{"lang": req.Config["title"]}
+	case req.Hook == hookPagesLoaded:
+		for _, p := range req.Pages {
+			switch p.Path {
+			case "hello.md":
+				p.Content = "Hello from a plugin"
+				res.Pages = append(res.Pages, p)
+			case "draft.md":
+				res.Pages = append(res.Pages, &PluginPage{Path: p.Path, Delete: true})
+			}
+		}
+		res.Pages = append(res.Pages, &PluginPage{
+			Path:    "generated.md",
+			Data:    map[string]interface{}{"title": "Generated", "section": "blog"},
+			Content: "Made by a plugin",
+		})
+	case req.Hook == hookPostRender:
+		s := strings.Replace(req.Content, "Hello", "Habari", -1)
+		res.Content = &s
+	case req.Hook == hookBuildDone:
+		fmt.Fprintf(os.Stderr, "built %d pages in %s\n", len(req.Pages), req.Destination)
+	}
+	json.NewEncoder(os.Stdout).Encode(res)
+	os.Exit(0)
+}
+
+func TestExternalPlugins(t *testing.T) {
+	bin, err := filepath.Abs(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := fmt.Sprintf(`
+title: Plugins
+theme: plain
+plugins:
+  - name: helper
+    command: %q
+    args: [-test.run=TestHelperPlugin]
+    hooks: [OnConfig, OnPagesLoaded, OnPostRender, OnBuildDone]
+    timeout: 2s
+`, bin)
+	files := map[string]string{
+		DefaultConfigFile:         config,
+		"hello.md":                "---\ntitle: Hello\nsection: blog\nparams:\n  a: 1\n---\nHello",
+		"draft.md":                "---\ntitle: Draft\nsection: blog\n---\nDraft",
+		"_themes/plain/post.html": `{{.Params.lang}}: {{.Page.HTML}}`,
+	}
+	dir := writeProject(t, plainTheme(files))
+	defer os.RemoveAll(dir)
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err = os.Chtimes(filepath.Join(dir, DefaultConfigFile), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv(pluginModeVar, "build")
+	defer os.Unsetenv(pluginModeVar)
+	app := New()
+	if err = app.Run(dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range app.pages {
+		if p.virtual && !p.ModTime.Equal(modTime) {
+			t.Errorf("expected %s to have the time of the configuration got %v", p.Path, p.ModTime)
+		}
+	}
+	out := filepath.Join(dir, OutputDir)
+	expect := map[string]string{
+		"blog/hello.html":     "Plugins: <p>Habari from a plugin</p>",
+		"blog/generated.html": "Plugins: <p>Made by a plugin</p>",
+	}
+	for name, v := range expect {
+		b, err := ioutil.ReadFile(filepath.Join(out, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(b)) != v {
+			t.Errorf("expected %s to be %q got %q", name, v, b)
+		}
+	}
+	if _, err = os.Stat(filepath.Join(out, "blog", "draft.html")); err == nil {
+		t.Error("expected the draft to be deleted by the plugin")
+	}
+
+	errs := map[string]string{
+		"fail":  "plugin helper: OnConfig: hello.md: bad word",
+		"sleep": "plugin helper: OnConfig: timed out after 2s",
+	}
+	for mode, v := range errs {
+		os.Setenv(pluginModeVar, mode)
+		if err = New().Run(dir); err == nil || err.Error() != v {
+			t.Errorf("%s: expected %q got %v", mode, v, err)
+		}
+	}
+}
+
+func TestPluginConfig(t *testing.T) {
+	cfg := &Config{Plugins: []*PluginConfig{
+		{Name: "a", Command: "a", Hooks: []string{"OnBuildDone"}},
+		{Name: "a", Hooks: []string{"OnSave"}},
+		{Name: "b", Module: "b.wasm", Hooks: []string{"OnBuildDone"}, Memory: -1},
+	}}
+	var msgs []string
+	for _, p := range cfg.Plugins {
+		msgs = append(msgs, checkPlugin(p)...)
+	}
+	if len(msgs) != 4 || msgs[0] != "missing command or module" || !strings.HasPrefix(msgs[1], "unknown hook OnSave") ||
+		!strings.HasPrefix(msgs[2], "hooks can't be set for modules") || !strings.HasPrefix(msgs[3], "memory must be") {
+		t.Errorf("unexpected problems %v", msgs)
+	}
+}
+
+func TestPluginPageData(t *testing.T) {
+	// yaml front matter decodes nested maps with interface{} keys, which older
+	// versions of encoding/json can't encode.
+	page := &Page{Path: "hello.md", Data: map[string]interface{}{
+		"params": map[interface{}]interface{}{"a": 1},
+	}}
+	data := (&processPlugin{root: "."}).page(page, false).Data
+	if _, ok := data["params"].(map[string]interface{}); !ok {
+		t.Errorf("expected the nested front matter to have string keys got %#v", data["params"])
+	}
+}
@@ -68,9 +68,12 @@ func (m *Matter) Handle(delim string, fn HandlerFunc) {
 	m.handlers[delim] = fn
 }
 
-// Parse parses the input and extract the frontmatter
+// Parse parses the input and extract the frontmatter. It is safe to call Parse
+// concurrently.
 func (m *Matter) Parse(input io.Reader) (front map[string]interface{}, body io.Reader, err error) {
-	return m.parse(input)
+	// the state of the split is kept per input.
+	c := &Matter{handlers: m.handlers, delim: m.delim}
+	return c.parse(input)
 }
 func (m *Matter) parse(input io.Reader) (front map[string]interface{}, body io.Reader, err error) {
 	var getFront = func(f string) string {
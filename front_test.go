@@ -83,3 +83,17 @@ func TestLargeFile(t *testing.T) {
 	//	body, _ := ioutil.ReadAll(b)
 	//	t.Error(string(body))
 }
+
+func TestMatterReuse(t *testing.T) {
+	m := NewYAML()
+	for i := 0; i < 2; i++ {
+		_, b, err := m.Parse(strings.NewReader(yamlPost))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(b)
+		if !strings.Contains(string(body), "A brave new world") {
+			t.Errorf("expected body got %q", body)
+		}
+	}
+}
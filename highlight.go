@@ -0,0 +1,172 @@
+package bongo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+)
+
+const (
+	//HighlightKey is the configuration key for syntax highlighting settings
+	HighlightKey = "highlight"
+
+	//DefaultHighlightStyle is the style used when none is configured
+	DefaultHighlightStyle = "github"
+
+	// fenceSep separates the language and options of a code block, after the
+	// info string has been rewritten by extractFences.
+	fenceSep = "|"
+)
+
+var fenceLine = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})(.*)$")
+
+type (
+	//HighlightConfig are the settings for highlighting fenced code blocks.
+	HighlightConfig struct {
+		Disable     bool   `yaml:"disable"`
+		Style       string `yaml:"style"`
+		Classes     bool   `yaml:"classes"`
+		LineNumbers bool   `yaml:"linenos"`
+	}
+
+	// highlighter renders fenced code blocks with syntax highlighting.
+	highlighter HighlightConfig
+
+	// fenceOptions are the settings of a single code block, given after the
+	// language in the info string, like
+	//
+	//	```go linenos linenostart=10 hl_lines=2,4-6
+	fenceOptions struct {
+		lang        string
+		lineNumbers bool
+		lineStart   int
+		lines       [][2]int
+	}
+)
+
+func newHighlighter(cfg HighlightConfig) (*highlighter, error) {
+	h := highlighter(cfg)
+	if h.Style == "" {
+		h.Style = DefaultHighlightStyle
+	}
+	if _, ok := styles.Registry[h.Style]; !ok {
+		return nil, fmt.Errorf("unknown highlight style %s", h.Style)
+	}
+	if h.Disable {
+		return nil, nil
+	}
+	return &h, nil
+}
+
+// render returns the highlighted html for code.
+func (h *highlighter) render(code string, opts *fenceOptions) (string, error) {
+	lexer := lexers.Get(opts.lang)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return "", err
+	}
+	fopts := []chromahtml.Option{
+		chromahtml.WithClasses(h.Classes),
+		chromahtml.WithLineNumbers(h.LineNumbers || opts.lineNumbers),
+		chromahtml.HighlightLines(opts.lines),
+	}
+	if opts.lineStart > 0 {
+		fopts = append(fopts, chromahtml.BaseLineNumber(opts.lineStart))
+	}
+	buf := &bytes.Buffer{}
+	err = chromahtml.New(fopts...).Format(buf, styles.Get(h.Style), it)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+//HighlightCSS writes the stylesheet for highlighted code blocks, when the
+// classes setting is used.
+func HighlightCSS(w io.Writer, style string) error {
+	s, ok := styles.Registry[style]
+	if !ok {
+		return fmt.Errorf("unknown highlight style %s", style)
+	}
+	return chromahtml.New(chromahtml.WithClasses(true)).WriteCSS(w, s)
+}
+
+// extractFences rewrites the info string of fenced code blocks which have
+// options after the language, so that they survive markdown parsing as a
+// single word.
+func extractFences(src string) string {
+	lines := strings.Split(src, "\n")
+	var open string
+	for i, line := range lines {
+		m := fenceLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if open != "" {
+			if m[2][0] == open[0] && len(m[2]) >= len(open) && strings.TrimSpace(m[3]) == "" {
+				open = ""
+			}
+			continue
+		}
+		open = m[2]
+		info := strings.Fields(m[3])
+		if len(info) > 1 {
+			lines[i] = m[1] + m[2] + strings.Join(info, fenceSep)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseFence parses the info string of a code block rewritten by
+// extractFences.
+func parseFence(info string) *fenceOptions {
+	parts := strings.Split(info, fenceSep)
+	opts := &fenceOptions{lang: parts[0]}
+	for _, p := range parts[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		val := ""
+		if len(kv) > 1 {
+			val = kv[1]
+		}
+		switch kv[0] {
+		case "linenos":
+			opts.lineNumbers = val == "" || val == "true"
+		case "linenostart":
+			opts.lineStart, _ = strconv.Atoi(val)
+		case "hl_lines":
+			opts.lines = parseLineRanges(val)
+		}
+	}
+	return opts
+}
+
+// parseLineRanges parses line ranges like 2,4-6
+func parseLineRanges(s string) [][2]int {
+	var rst [][2]int
+	for _, r := range strings.Split(s, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		start, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			continue
+		}
+		end := start
+		if len(bounds) > 1 {
+			if end, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+				continue
+			}
+		}
+		rst = append(rst, [2]int{start, end})
+	}
+	return rst
+}
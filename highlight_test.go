@@ -0,0 +1,54 @@
+package bongo
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var fencedPost = "Some code\n\n```go linenos hl_lines=1,3-4\npackage main\n\nfunc main() {\n}\n```\n\n```\nplain\n```\n"
+
+func TestExtractFences(t *testing.T) {
+	out := extractFences(fencedPost)
+	if !strings.Contains(out, "```go|linenos|hl_lines=1,3-4\n") {
+		t.Errorf("expected the info string to be rewritten got %s", out)
+	}
+	opts := parseFence("go|linenos|linenostart=5|hl_lines=1,3-4")
+	expect := &fenceOptions{lang: "go", lineNumbers: true, lineStart: 5, lines: [][2]int{{1, 1}, {3, 4}}}
+	if !reflect.DeepEqual(opts, expect) {
+		t.Errorf("expected %v got %v", expect, opts)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	ctx, err := newRenderContext("", &Config{
+		Highlight: HighlightConfig{Style: "monokai", Classes: true},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Page{Body: []byte(fencedPost), ctx: ctx}
+	out := string(p.HTML())
+	if !strings.Contains(out, `class="chroma"`) || !strings.Contains(out, `class="line hl"`) {
+		t.Errorf("expected highlighted code got %s", out)
+	}
+	if !strings.Contains(out, "<code>plain") {
+		t.Errorf("expected code without language to be left alone got %s", out)
+	}
+
+	_, err = newRenderContext("", &Config{
+		Highlight: HighlightConfig{Style: "nope"},
+	}, nil)
+	if err == nil {
+		t.Error("expected an error for unknown style")
+	}
+
+	buf := &bytes.Buffer{}
+	if err = HighlightCSS(buf, "monokai"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), ".chroma") {
+		t.Errorf("expected chroma css got %s", buf.String())
+	}
+}
@@ -0,0 +1,187 @@
+package bongo
+
+import (
+	"fmt"
+	"html/template"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	//I18nDir is the directory at the project root with the translated strings of
+	// every language, in files named after the language code like sw.yml
+	I18nDir = "_i18n"
+
+	//LanguageKey is the key used to store the *Language of the rendered pages in
+	// the template context
+	LanguageKey = "Language"
+
+	//LanguagesKey is the key used to store all the languages in the template
+	// context
+	LanguagesKey = "Languages"
+
+	pageTranslationKey = "translationKey"
+)
+
+//Language is a language the site is published in.
+type Language struct {
+	// Code is the key of the language in the configuration, like sw. Pages in
+	// languages other than the default one are generated in a directory named
+	// after the code.
+	Code   string `yaml:"-"`
+	Name   string `yaml:"name"`
+	Weight int    `yaml:"weight"`
+}
+
+type languages []*Language
+
+func (l languages) Len() int { return len(l) }
+func (l languages) Less(i, j int) bool {
+	if l[i].Weight != l[j].Weight {
+		return l[i].Weight < l[j].Weight
+	}
+	return l[i].Code < l[j].Code
+}
+func (l languages) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+// languages returns the configured languages sorted by weight. Sites without
+// languages have a single language, the default one.
+func (c *Config) languages() []*Language {
+	if len(c.Languages) == 0 {
+		return []*Language{{Code: c.DefaultLanguage}}
+	}
+	var rst languages
+	for code, l := range c.Languages {
+		if l == nil {
+			l = &Language{}
+			c.Languages[code] = l
+		}
+		l.Code = code
+		rst = append(rst, l)
+	}
+	sort.Sort(rst)
+	return rst
+}
+
+// langPrefix returns the url prefix of pages in the language code.
+func (c *Config) langPrefix(code string) string {
+	if code == "" || code == c.DefaultLanguage {
+		return ""
+	}
+	return "/" + code
+}
+
+// pageLanguage returns the language of the file, and its path relative to
+// root without the language. The language is the suffix of the file name, like
+// post.sw.md, or the name of a directory the file is in, like sw/post.md. Files
+// without a language are in the default language.
+func (c *Config) pageLanguage(root, file string) (lang, key string) {
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		rel = file
+	}
+	key = filepath.ToSlash(rel)
+	if len(c.Languages) == 0 {
+		return c.DefaultLanguage, key
+	}
+	dir, base := path.Split(key)
+	ext := path.Ext(base)
+	name := strings.TrimSuffix(base, ext)
+	if l := path.Ext(name); l != "" && c.Languages[l[1:]] != nil {
+		return l[1:], dir + strings.TrimSuffix(name, l) + ext
+	}
+	parts := strings.Split(key, "/")
+	for i, part := range parts[:len(parts)-1] {
+		if _, ok := c.Languages[part]; ok {
+			rest := append(append([]string{}, parts[:i]...), parts[i+1:]...)
+			return part, strings.Join(rest, "/")
+		}
+	}
+	return c.DefaultLanguage, key
+}
+
+// linkTranslations sets the translations of every page, which are the pages in
+// other languages with the same path, or the same translationKey front matter.
+func linkTranslations(pages PageList, langs []*Language) {
+	weight := make(map[string]int)
+	for i, l := range langs {
+		weight[l.Code] = i
+	}
+	keys := make(map[string]PageList)
+	for _, p := range pages {
+		keys[p.translationKey()] = append(keys[p.translationKey()], p)
+	}
+	for _, p := range pages {
+		p.translations = nil
+		for _, t := range keys[p.translationKey()] {
+			if t.lang != p.lang {
+				p.translations = append(p.translations, t)
+			}
+		}
+		sort.Sort(byLanguage{p.translations, weight})
+	}
+}
+
+type byLanguage struct {
+	pages  PageList
+	weight map[string]int
+}
+
+func (b byLanguage) Len() int           { return len(b.pages) }
+func (b byLanguage) Less(i, j int) bool { return b.weight[b.pages[i].lang] < b.weight[b.pages[j].lang] }
+func (b byLanguage) Swap(i, j int)      { b.pages[i], b.pages[j] = b.pages[j], b.pages[i] }
+
+// translator looks up strings in the translation tables loaded from the i18n
+// directory.
+type translator struct {
+	tables   map[string]interface{}
+	lang     string
+	fallback string
+}
+
+// translate returns the string key in the language of t, or in the default
+// language if it is missing. Nested keys are separated with dots. The string is
+// used as a format for args, if there are any. The key is returned when there
+// is no translation.
+func (t *translator) translate(key string, args ...interface{}) string {
+	for _, code := range []string{t.lang, t.fallback} {
+		v := t.tables[code]
+		for _, k := range strings.Split(key, ".") {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				v = nil
+				break
+			}
+			v = m[k]
+		}
+		if s, ok := v.(string); ok {
+			if len(args) > 0 {
+				return fmt.Sprintf(s, args...)
+			}
+			return s
+		}
+	}
+	return key
+}
+
+// templateFuncs are the functions available to theme templates, the i18n
+// function, absURL, the asset pipeline and image processing. They are replaced
+// for every language before the templates are executed.
+func templateFuncs(t *translator, assets *assetSet, images *imageSet, baseURL string) template.FuncMap {
+	if t == nil {
+		t = &translator{}
+	}
+	funcs := template.FuncMap{
+		"i18n":   t.translate,
+		"absURL": func(u string) string { return absURL(baseURL, u) },
+	}
+	for k, v := range assets.funcs() {
+		funcs[k] = v
+	}
+	for k, v := range images.funcs() {
+		funcs[k] = v
+	}
+	return funcs
+}
@@ -0,0 +1,73 @@
+package bongo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var multilingualSite = plainTheme(map[string]string{
+	DefaultConfigFile: `
+theme: plain
+languages:
+  en:
+    name: English
+    weight: 1
+  sw:
+    name: Kiswahili
+    weight: 2
+`,
+	"_i18n/en.yml":             "read: Read %s\nnav:\n  home: Home\n",
+	"_i18n/sw.yml":             "read: Soma %s\n",
+	"_themes/plain/post.html":  `{{i18n "read" .Page.Title}} {{i18n "nav.home"}}{{range .Page.Translations}} <a href="{{.URL}}">{{.Lang}}</a>{{end}}`,
+	"_themes/plain/index.html": `{{.Section.URL}}`,
+	"_themes/plain/home.html":  `{{.Language.Name}}`,
+	"hello.md":                 "---\ntitle: Hello\nsection: blog\n---\nHello",
+	"hello.sw.md":              "---\ntitle: Habari\nsection: blog\n---\nHabari",
+	"sw/about.md":              "---\ntitle: Kuhusu\n---\nKuhusu",
+})
+
+func TestMultilingual(t *testing.T) {
+	dir := writeProject(t, multilingualSite)
+	defer os.RemoveAll(dir)
+	err := New().Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{
+		"_site/blog/hello.html":    `Read Hello Home <a href="/sw/blog/hello.html">sw</a>`,
+		"_site/sw/blog/hello.html": `Soma Habari Home <a href="/blog/hello.html">en</a>`,
+		"_site/sw/home/about.html": `Soma Kuhusu Home`,
+		"_site/sw/blog/index.html": `/sw/blog/index.html`,
+		"_site/index.html":         `English`,
+		"_site/sw/index.html":      `Kiswahili`,
+	}
+	for name, v := range expect {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if strings.TrimSpace(string(b)) != v {
+			t.Errorf("%s: expected %q got %q", name, v, b)
+		}
+	}
+}
+
+func TestPageLanguage(t *testing.T) {
+	cfg := &Config{DefaultLanguage: "en", Languages: map[string]*Language{"en": {}, "sw": {}}}
+	cases := []struct{ file, lang, key string }{
+		{"site/post.md", "en", "post.md"},
+		{"site/post.sw.md", "sw", "post.md"},
+		{"site/content/sw/docs/intro.md", "sw", "content/docs/intro.md"},
+		{"site/content/fr/intro.md", "en", "content/fr/intro.md"},
+	}
+	for _, c := range cases {
+		lang, key := cfg.pageLanguage("site", filepath.FromSlash(c.file))
+		if lang != c.lang || key != c.key {
+			t.Errorf("%s: expected %s %s got %s %s", c.file, c.lang, c.key, lang, key)
+		}
+	}
+}
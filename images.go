@@ -0,0 +1,373 @@
+package bongo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	//ImagesKey is the configuration key for image processing settings
+	ImagesKey = "images"
+
+	//DefaultImageQuality is the quality of generated JPEG images
+	DefaultImageQuality = 75
+
+	//DefaultImageCache is the directory, relative to the project root, where
+	// processed images are kept between builds.
+	DefaultImageCache = "_cache/images"
+)
+
+var (
+	// DefaultImageWidths are the widths of the srcset variants of images.
+	DefaultImageWidths = []int{480, 800, 1200}
+
+	imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".JPG", ".JPEG", ".PNG", ".GIF"}
+
+	imageAnchors = map[string]imaging.Anchor{
+		"center":      imaging.Center,
+		"top":         imaging.Top,
+		"bottom":      imaging.Bottom,
+		"left":        imaging.Left,
+		"right":       imaging.Right,
+		"topleft":     imaging.TopLeft,
+		"topright":    imaging.TopRight,
+		"bottomleft":  imaging.BottomLeft,
+		"bottomright": imaging.BottomRight,
+	}
+)
+
+type (
+	//ImagesConfig are the settings for image processing.
+	ImagesConfig struct {
+		// Widths are the widths of the srcset variants.
+		Widths []int `yaml:"widths"`
+
+		// Quality is the quality of JPEG images, from 1 to 100.
+		Quality int `yaml:"quality"`
+
+		// Cache is the directory where processed images are kept.
+		Cache string `yaml:"cache"`
+	}
+
+	//Image is an image in the project, or the result of processing one. For
+	// instance in a template
+	//
+	//	{{with image "media/photo.jpg"}}
+	//		{{$thumb := .Fill "300x200 center"}}
+	//		<img src="{{$thumb.URL}}" width="{{$thumb.Width}}" height="{{$thumb.Height}}">
+	//	{{end}}
+	//
+	// The image is written to the output directory when its URL is used.
+	Image struct {
+		// Name is the path of the image relative to the output directory.
+		Name          string
+		Width, Height int
+
+		// file is where the content of the image is read from, and hash
+		// identifies the content.
+		file string
+		hash string
+		set  *imageSet
+	}
+
+	// imageSpec are the options of an image operation, given as a string like
+	//
+	//	800x600 topleft png q90
+	imageSpec struct {
+		width, height int
+		anchor        imaging.Anchor
+		format        string
+		quality       int
+	}
+
+	// imageSet processes the images of a build.
+	imageSet struct {
+		root  string
+		out   string
+		cache string
+		cfg   ImagesConfig
+		write func(file string, b []byte) error
+
+		mu        sync.Mutex
+		images    map[string]*Image
+		published map[string]bool
+		err       error
+	}
+)
+
+func newImageSet(root, out string, cfg ImagesConfig, write func(string, []byte) error) *imageSet {
+	if cfg.Quality <= 0 {
+		cfg.Quality = DefaultImageQuality
+	}
+	if len(cfg.Widths) == 0 {
+		cfg.Widths = DefaultImageWidths
+	}
+	if cfg.Cache == "" {
+		cfg.Cache = DefaultImageCache
+	}
+	cache := cfg.Cache
+	if !filepath.IsAbs(cache) {
+		cache = filepath.Join(root, cache)
+	}
+	return &imageSet{
+		root:      root,
+		out:       out,
+		cache:     cache,
+		cfg:       cfg,
+		write:     write,
+		images:    make(map[string]*Image),
+		published: make(map[string]bool),
+	}
+}
+
+// funcs returns the image template functions.
+func (s *imageSet) funcs() map[string]interface{} {
+	return map[string]interface{}{"image": s.image}
+}
+
+// image returns the image name, relative to the project root.
+func (s *imageSet) image(name string) (*Image, error) {
+	if s == nil {
+		return nil, fmt.Errorf("images are only available when rendering")
+	}
+	clean := path.Clean("/" + filepath.ToSlash(name))[1:]
+	if clean == "" || strings.HasPrefix(filepath.ToSlash(name), "../") {
+		return nil, fmt.Errorf("image %s is outside the project", name)
+	}
+	if !HasExt(clean, imageExtensions...) {
+		return nil, fmt.Errorf("image %s is not a JPEG, PNG or GIF file", name)
+	}
+	s.mu.Lock()
+	img, ok := s.images[clean]
+	s.mu.Unlock()
+	if ok {
+		return img, nil
+	}
+	file := filepath.Join(s.root, filepath.FromSlash(clean))
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("image %s %v", name, err)
+	}
+	img = &Image{
+		Name:   clean,
+		Width:  cfg.Width,
+		Height: cfg.Height,
+		file:   file,
+		hash:   fmt.Sprintf("%x", sha256.Sum256(b)),
+		set:    s,
+	}
+	s.mu.Lock()
+	s.images[clean] = img
+	s.mu.Unlock()
+	return img, nil
+}
+
+//URL returns the url of the image, and writes it to the output directory.
+func (i *Image) URL() string {
+	i.set.publish(i)
+	return "/" + i.Name
+}
+
+func (i *Image) String() string {
+	return i.URL()
+}
+
+//Resize scales the image to the width and height in spec, like 800x600. When
+// the width or height is missing, like 800x, it is set to keep the aspect
+// ratio.
+func (i *Image) Resize(spec string) (*Image, error) {
+	return i.set.process(i, "resize", spec)
+}
+
+//Fit scales the image down to fit in the width and height in spec, keeping the
+// aspect ratio. Both the width and the height are required.
+func (i *Image) Fit(spec string) (*Image, error) {
+	return i.set.process(i, "fit", spec)
+}
+
+//Fill scales and crops the image to fill the width and height in spec, which
+// are both required. The part of the image which is kept is given by an anchor
+// like center or topleft.
+func (i *Image) Fill(spec string) (*Image, error) {
+	return i.set.process(i, "fill", spec)
+}
+
+//Crop cuts out the width and height in spec from the image, without scaling it.
+// Both the width and the height are required.
+func (i *Image) Crop(spec string) (*Image, error) {
+	return i.set.process(i, "crop", spec)
+}
+
+//Srcset returns the value of the srcset attribute, with variants of the image in
+// the configured widths which are smaller than the image.
+func (i *Image) Srcset() (string, error) {
+	var rst []string
+	for _, w := range i.set.cfg.Widths {
+		if w >= i.Width {
+			continue
+		}
+		v, err := i.Resize(fmt.Sprintf("%dx", w))
+		if err != nil {
+			return "", err
+		}
+		rst = append(rst, fmt.Sprintf("%s %dw", v.URL(), v.Width))
+	}
+	rst = append(rst, fmt.Sprintf("%s %dw", i.URL(), i.Width))
+	return strings.Join(rst, ", "), nil
+}
+
+// process returns the result of the operation op on the image src. Results are
+// kept in the cache directory, keyed by the source hash, op and spec, so that
+// images are processed only once across builds.
+func (s *imageSet) process(src *Image, op, spec string) (*Image, error) {
+	o, err := parseImageSpec(op, spec, s.cfg.Quality)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %v", op, src.Name, err)
+	}
+	ext := path.Ext(src.Name)
+	if o.format != "" {
+		ext = "." + o.format
+	}
+	format, err := imaging.FormatFromExtension(ext)
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%d|%d|%s|%d", src.hash, op, o.width, o.height, o.anchor, ext, o.quality))))
+	name := strings.TrimSuffix(src.Name, path.Ext(src.Name)) + "_" + key[:12] + ext
+
+	s.mu.Lock()
+	img, ok := s.images[name]
+	s.mu.Unlock()
+	if ok {
+		return img, nil
+	}
+	file := filepath.Join(s.cache, key+ext)
+	img = &Image{Name: name, file: file, hash: key, set: s}
+
+	if f, err := os.Open(file); err == nil {
+		cfg, _, cerr := image.DecodeConfig(f)
+		f.Close()
+		if cerr == nil {
+			img.Width, img.Height = cfg.Width, cfg.Height
+			s.add(img)
+			return img, nil
+		}
+	}
+
+	m, err := imaging.Open(src.file, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %v", op, src.Name, err)
+	}
+	switch op {
+	case "resize":
+		m = imaging.Resize(m, o.width, o.height, imaging.Lanczos)
+	case "fit":
+		m = imaging.Fit(m, o.width, o.height, imaging.Lanczos)
+	case "fill":
+		m = imaging.Fill(m, o.width, o.height, o.anchor, imaging.Lanczos)
+	case "crop":
+		m = imaging.CropAnchor(m, o.width, o.height, o.anchor)
+	}
+	buf := &bytes.Buffer{}
+	if err = imaging.Encode(buf, m, format, imaging.JPEGQuality(o.quality)); err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(s.cache, DefaultDirPerm); err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(file, buf.Bytes(), DefaultPerm); err != nil {
+		return nil, err
+	}
+	b := m.Bounds()
+	img.Width, img.Height = b.Dx(), b.Dy()
+	s.add(img)
+	return img, nil
+}
+
+func (s *imageSet) add(img *Image) {
+	s.mu.Lock()
+	s.images[img.Name] = img
+	s.mu.Unlock()
+}
+
+// publish copies the image to the output directory, the first time it is
+// called for it. Errors are kept, and returned by the build.
+func (s *imageSet) publish(img *Image) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file := filepath.Join(s.out, filepath.FromSlash(img.Name))
+	if s.published[file] {
+		return
+	}
+	s.published[file] = true
+	b, err := ioutil.ReadFile(img.file)
+	if err == nil {
+		err = s.write(file, b)
+	}
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+// parseImageSpec parses the options of the image operation op. quality is the
+// default JPEG quality.
+func parseImageSpec(op, spec string, quality int) (*imageSpec, error) {
+	o := &imageSpec{anchor: imaging.Center, quality: quality}
+	for _, f := range strings.Fields(strings.ToLower(spec)) {
+		if a, ok := imageAnchors[f]; ok {
+			o.anchor = a
+			continue
+		}
+		switch f {
+		case "jpg", "jpeg", "png", "gif":
+			o.format = f
+			continue
+		}
+		if strings.HasPrefix(f, "q") {
+			q, err := strconv.Atoi(f[1:])
+			if err != nil || q < 1 || q > 100 {
+				return nil, fmt.Errorf("bad quality %s", f)
+			}
+			o.quality = q
+			continue
+		}
+		i := strings.Index(f, "x")
+		if i < 0 {
+			return nil, fmt.Errorf("unknown option %s", f)
+		}
+		var err error
+		if w := f[:i]; w != "" {
+			if o.width, err = strconv.Atoi(w); err != nil {
+				return nil, fmt.Errorf("bad size %s", f)
+			}
+		}
+		if h := f[i+1:]; h != "" {
+			if o.height, err = strconv.Atoi(h); err != nil {
+				return nil, fmt.Errorf("bad size %s", f)
+			}
+		}
+	}
+	if o.width < 0 || o.height < 0 || (o.width == 0 && o.height == 0) {
+		return nil, fmt.Errorf("missing size in %q", spec)
+	}
+	// only resize can keep the aspect ratio with one dimension.
+	if op != "resize" && (o.width == 0 || o.height == 0) {
+		return nil, fmt.Errorf("%s needs a width and a height, got %q", op, spec)
+	}
+	return o, nil
+}
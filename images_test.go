@@ -0,0 +1,122 @@
+package bongo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestImages(t *testing.T) {
+	m := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 200; y++ {
+			m.Set(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, m); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		DefaultConfigFile: "theme: plain\nimages:\n  widths: [100, 300, 800]\n",
+		"media/photo.png": buf.String(),
+		"blog/photo.md":   "---\ntitle: Photo\nsection: blog\n---\n{{< figure src=\"/media/photo.png\" >}}\n{{< figure src=\"https://example.com/a.png\" >}}\n",
+		"_themes/plain/post.html": `{{.Page.HTML}}{{with image "media/photo.png"}}{{with .Fill "50x50 topleft jpg"}}` +
+			`<img src="{{.URL}}" width="{{.Width}}" height="{{.Height}}">{{end}}{{end}}`,
+	}
+	dir := writeProject(t, plainTheme(files))
+	defer os.RemoveAll(dir)
+	err := New().Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, OutputDir)
+	b, err := ioutil.ReadFile(filepath.Join(out, "blog", "photo.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcset := regexp.MustCompile(`srcset="(/media/photo_[0-9a-f]{12}\.png) 100w, (/media/photo_[0-9a-f]{12}\.png) 300w, /media/photo\.png 400w" alt="" width="400" height="200"`).FindStringSubmatch(string(b))
+	if srcset == nil {
+		t.Fatalf("expected a responsive figure got %s", b)
+	}
+	if !bytes.Contains(b, []byte(`<img src="https://example.com/a.png" alt="">`)) {
+		t.Errorf("expected external images to be left alone got %s", b)
+	}
+	sizes := map[string]int{srcset[1]: 50, srcset[2]: 150}
+	for name, h := range sizes {
+		f, err := os.Open(filepath.Join(out, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, _, err := image.DecodeConfig(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Height != h {
+			t.Errorf("expected %s to be %d pixels high got %d", name, h, cfg.Height)
+		}
+	}
+	fill := regexp.MustCompile(`<img src="(/media/photo_[0-9a-f]{12}\.jpg)" width="50" height="50">`).FindStringSubmatch(string(b))
+	if fill == nil {
+		t.Fatalf("expected a filled jpeg image got %s", b)
+	}
+	if _, err = os.Stat(filepath.Join(out, filepath.FromSlash(fill[1]))); err != nil {
+		t.Error(err)
+	}
+
+	// processed images are taken from the cache on the next build.
+	cached, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(DefaultImageCache), "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cached) != 3 {
+		t.Fatalf("expected 3 cached images got %v", cached)
+	}
+	for _, f := range cached {
+		if err = ioutil.WriteFile(f, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = New().Run(dir); err != nil {
+		t.Fatal(err)
+	}
+	b, err = ioutil.ReadFile(filepath.Join(out, "blog", "photo.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(srcset[1]+" 400w")) {
+		t.Errorf("expected the cached image to be used got %s", b)
+	}
+}
+
+func TestParseImageSpec(t *testing.T) {
+	o, err := parseImageSpec("resize", "300x BottomRight png q90", DefaultImageQuality)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.width != 300 || o.height != 0 || o.format != "png" || o.quality != 90 {
+		t.Errorf("unexpected spec %+v", o)
+	}
+	for _, spec := range []string{"", "x", "300x200 sideways", "300 q101", "axb"} {
+		if _, err = parseImageSpec("resize", spec, DefaultImageQuality); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+	for _, op := range []string{"fit", "fill", "crop"} {
+		for _, spec := range []string{"300x", "x200 topleft"} {
+			if _, err = parseImageSpec(op, spec, DefaultImageQuality); err == nil {
+				t.Errorf("%s: expected an error for %q", op, spec)
+			}
+		}
+		if _, err = parseImageSpec(op, "300x200", DefaultImageQuality); err != nil {
+			t.Errorf("%s: %v", op, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package bongo
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	regionStart = regexp.MustCompile(`(?:^|[^\w])region:\s*([\w.-]+)`)
+	regionEnd   = regexp.MustCompile(`(?:^|[^\w])endregion:\s*([\w.-]+)`)
+)
+
+// Include returns the content of the file given in the file argument, or
+// as the first positional argument. The path is relative to the project root.
+// Markdown files are rendered, other files are shown as code.
+//
+// The lines argument limits the content to a range of lines like 10-40, and
+// the region argument to the lines between the markers
+//
+//	// region: name
+//	// endregion: name
+//
+// The file is recorded as a dependency of the page.
+func (s *Shortcode) Include() (template.HTML, error) {
+	name := s.Get("file")
+	if name == "" {
+		name = s.Get(0)
+	}
+	if name == "" {
+		return "", errors.New("include needs a file")
+	}
+	b, err := s.readFile(name)
+	if err != nil {
+		return "", err
+	}
+	src := string(b)
+	if r := s.Get("region"); r != "" {
+		if src, err = extractRegion(src, r); err != nil {
+			return "", fmt.Errorf("%s %v", name, err)
+		}
+	}
+	if l := s.Get("lines"); l != "" {
+		if src, err = extractLines(src, l); err != nil {
+			return "", fmt.Errorf("%s %v", name, err)
+		}
+	}
+	if HasExt(name, supportedExtensions...) {
+		return s.Markdownify(src), nil
+	}
+	lang := s.Get("lang")
+	if lang == "" {
+		lang = strings.TrimPrefix(filepath.Ext(name), ".")
+	}
+	return s.Highlight(src, lang, s.Get("opts"))
+}
+
+// readFile reads a file relative to the project root, and records it as a
+// dependency of the page. Files outside the project root can't be read.
+func (s *Shortcode) readFile(name string) ([]byte, error) {
+	root := "."
+	if s.ctx != nil && s.ctx.root != "" {
+		root = s.ctx.root
+	}
+	file := filepath.Join(root, filepath.FromSlash(name))
+	rel, err := filepath.Rel(root, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s is outside the project", name)
+	}
+	if s.Page != nil {
+		s.Page.addDependency(file)
+	}
+	return ioutil.ReadFile(file)
+}
+
+// extractLines returns the lines of src in the range r, like 10-40. Both ends
+// are included, and either can be omitted like 10- or -40.
+func extractLines(src, r string) (string, error) {
+	lines := strings.Split(src, "\n")
+	bounds := strings.SplitN(r, "-", 2)
+	start, end := 1, len(lines)
+	var err error
+	if b := strings.TrimSpace(bounds[0]); b != "" {
+		if start, err = strconv.Atoi(b); err != nil {
+			return "", fmt.Errorf("bad line range %s", r)
+		}
+	}
+	if len(bounds) == 1 {
+		end = start
+	} else if b := strings.TrimSpace(bounds[1]); b != "" {
+		if end, err = strconv.Atoi(b); err != nil {
+			return "", fmt.Errorf("bad line range %s", r)
+		}
+	}
+	if start < 1 || end > len(lines) || start > end {
+		return "", fmt.Errorf("line range %s is out of bounds, the file has %d lines", r, len(lines))
+	}
+	return strings.Join(lines[start-1:end], "\n") + "\n", nil
+}
+
+// extractRegion returns the lines of src between the markers of the region
+// name. Lines with markers of other regions are left out, and the common
+// indentation is removed.
+func extractRegion(src, name string) (string, error) {
+	var (
+		rst   []string
+		found bool
+	)
+	for _, line := range strings.Split(src, "\n") {
+		if m := regionEnd.FindStringSubmatch(line); m != nil {
+			if found && m[1] == name {
+				return dedent(rst), nil
+			}
+			continue
+		}
+		if m := regionStart.FindStringSubmatch(line); m != nil {
+			if m[1] == name {
+				found = true
+			}
+			continue
+		}
+		if found {
+			rst = append(rst, line)
+		}
+	}
+	if found {
+		return "", fmt.Errorf("missing end of region %s", name)
+	}
+	return "", fmt.Errorf("missing region %s", name)
+}
+
+// dedent removes the indentation common to all non blank lines.
+func dedent(lines []string) string {
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, prefix)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
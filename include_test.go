@@ -0,0 +1,82 @@
+package bongo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var snippetSource = `package main
+
+import "fmt"
+
+// region: main
+func main() {
+	// region: print
+	fmt.Println("hello")
+	// endregion: print
+}
+// endregion: main
+`
+
+func TestExtractSnippets(t *testing.T) {
+	out, err := extractLines(snippetSource, "3-3")
+	if err != nil || out != "import \"fmt\"\n" {
+		t.Errorf("unexpected lines %q %v", out, err)
+	}
+	if _, err = extractLines(snippetSource, "10-40"); err == nil {
+		t.Error("expected out of bounds error")
+	}
+	out, err = extractRegion(snippetSource, "print")
+	if err != nil || out != "fmt.Println(\"hello\")\n" {
+		t.Errorf("unexpected region %q %v", out, err)
+	}
+	out, err = extractRegion(snippetSource, "main")
+	if err != nil || out != "func main() {\n\tfmt.Println(\"hello\")\n}\n" {
+		t.Errorf("unexpected region %q %v", out, err)
+	}
+	if _, err = extractRegion(snippetSource, "nope"); err == nil {
+		t.Error("expected missing region error")
+	}
+}
+
+func TestIncludeDependencies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bongo-include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "main.go")
+	if err = ioutil.WriteFile(file, []byte(snippetSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := newRenderContext(dir, &Config{
+		Highlight: HighlightConfig{Disable: true},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Page{Path: "post.md", Body: []byte(`{{< include "main.go" region="print" >}}`), ctx: ctx}
+	out := string(p.HTML())
+	if len(p.errs) > 0 {
+		t.Fatal(p.errs[0])
+	}
+	if !strings.Contains(out, `<pre><code class="lang-go">fmt.Println(&#34;hello&#34;)`) {
+		t.Errorf("expected the print region got %s", out)
+	}
+	deps := p.Dependencies()
+	if len(deps) != 1 || deps[0] != file {
+		t.Errorf("expected %s as dependency got %v", file, deps)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "..main.go"), []byte(snippetSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p = &Page{Path: "post.md", Body: []byte(`{{< include "..main.go" region="print" >}}`), ctx: ctx}
+	p.HTML()
+	if len(p.errs) > 0 {
+		t.Errorf("expected ..main.go to be in the project got %v", p.errs[0])
+	}
+}
@@ -0,0 +1,104 @@
+package bongo
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// linkResolver maps markdown source files to the URLs of the pages generated
+// from them, so that links like [next](1.2.md) point to the rendered output.
+type linkResolver struct {
+	root  string
+	pages map[string]string
+}
+
+func newLinkResolver(root string, pages PageList) *linkResolver {
+	r := &linkResolver{root: root, pages: make(map[string]string)}
+	for _, p := range pages {
+		r.pages[filepath.Clean(p.Path)] = p.URL()
+	}
+	return r
+}
+
+// resolve returns the url that href should point to when it appears in a
+// page generated from src. ok is false when href points to a markdown file
+// which is not part of the site.
+func (r *linkResolver) resolve(src, href string) (link string, ok bool) {
+	u, err := url.Parse(href)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return href, true
+	}
+	if !HasExt(u.Path, supportedExtensions...) {
+		return href, true
+	}
+	target := filepath.FromSlash(u.Path)
+	if path.IsAbs(u.Path) {
+		target = filepath.Join(r.root, target)
+	} else {
+		target = filepath.Join(filepath.Dir(src), target)
+	}
+	dest, found := r.pages[filepath.Clean(target)]
+	if !found {
+		return href, false
+	}
+	if u.Fragment != "" {
+		dest += "#" + u.Fragment
+	}
+	return dest, true
+}
+
+// rewrite replaces links to markdown sources in the html generated for page p
+// with the urls of the rendered pages. Links which can't be resolved are left
+// as they are and recorded on the page.
+func (r *linkResolver) rewrite(p *Page, src string) string {
+	return rewriteLinks(src, func(href string) string {
+		link, ok := r.resolve(p.Path, href)
+		if !ok {
+			p.addUnresolved(href)
+		}
+		return link
+	})
+}
+
+// rewriteLinks passes the href attribute of every anchor in src through fn.
+func rewriteLinks(src string, fn func(string) string) string {
+	var buf bytes.Buffer
+	z := html.NewTokenizer(strings.NewReader(src))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			buf.Write(raw)
+			continue
+		}
+		tok := z.Token()
+		if tok.Data != "a" {
+			buf.Write(raw)
+			continue
+		}
+		changed := false
+		for i, a := range tok.Attr {
+			if a.Key != "href" {
+				continue
+			}
+			if v := fn(a.Val); v != a.Val {
+				tok.Attr[i].Val = v
+				changed = true
+			}
+		}
+		if !changed {
+			buf.Write(raw)
+			continue
+		}
+		buf.WriteString(tok.String())
+	}
+	return buf.String()
+}
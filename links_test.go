@@ -0,0 +1,43 @@
+package bongo
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLinkResolver(t *testing.T) {
+	root := "site"
+	pages := PageList{
+		{Path: filepath.Join(root, "1.0.md"), Data: map[string]interface{}{"section": "blog"}},
+		{Path: filepath.Join(root, "docs", "1.2.md"), Data: map[string]interface{}{"section": "blog"}},
+		{Path: filepath.Join(root, "about.md"), Data: map[string]interface{}{}},
+	}
+	r := newLinkResolver(root, pages)
+	sample := []struct {
+		href, link string
+		ok         bool
+	}{
+		{"docs/1.2.md", "/blog/1.2.html", true},
+		{"docs/1.2.md#install", "/blog/1.2.html#install", true},
+		{"/about.md", "/home/about.html", true},
+		{"missing.md", "missing.md", false},
+		{"https://example.com/readme.md", "https://example.com/readme.md", true},
+		{"#top", "#top", true},
+		{"media/image.png", "media/image.png", true},
+	}
+	for _, v := range sample {
+		link, ok := r.resolve(pages[0].Path, v.href)
+		if link != v.link || ok != v.ok {
+			t.Errorf("%s: expected %s %v got %s %v", v.href, v.link, v.ok, link, ok)
+		}
+	}
+
+	out := r.rewrite(pages[1], `<p><a href="../1.0.md" title="one">one</a> <a href="nope.md">two</a></p>`)
+	if !strings.Contains(out, `href="/blog/1.0.html"`) {
+		t.Errorf("expected link to be rewritten got %s", out)
+	}
+	if len(pages[1].unresolved) != 1 || pages[1].unresolved[0] != "nope.md" {
+		t.Errorf("expected nope.md to be unresolved got %v", pages[1].unresolved)
+	}
+}
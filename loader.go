@@ -3,13 +3,16 @@ package bongo
 import (
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 var supportedExtensions = []string{".md", ".MD", "..markdown"}
 
 //DefaultLoader  is the default FileLoader implementation
-type DefaultLoader struct{}
+type DefaultLoader struct {
+	// Exclude are directories which are not loaded, the OutputDir of the
+	// project is excluded when it is empty.
+	Exclude []string
+}
 
 // NewLoader returns default FileLoader implementation.
 func NewLoader() *DefaultLoader {
@@ -20,16 +23,27 @@ func NewLoader() *DefaultLoader {
 func (d DefaultLoader) Load(base string) ([]string, error) {
 	return func(base string) ([]string, error) {
 		var rst []string
+		exclude := d.Exclude
+		if len(exclude) == 0 {
+			exclude = []string{filepath.Join(base, OutputDir)}
+		}
+		skip := make(map[string]bool)
+		for _, dir := range exclude {
+			if abs, err := filepath.Abs(dir); err == nil {
+				skip[abs] = true
+			}
+		}
 		err := filepath.Walk(base, func(path string, info os.FileInfo, err error) error {
 			switch {
 			case err != nil:
 				return err
 			case info.IsDir():
+				if abs, aerr := filepath.Abs(path); aerr == nil && skip[abs] {
+					return filepath.SkipDir
+				}
 				return nil
 			case !HasExt(path, supportedExtensions...):
 				return nil
-			case strings.Contains(path, OutputDir):
-				return nil
 			}
 			rst = append(rst, path)
 			return nil
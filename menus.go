@@ -0,0 +1,199 @@
+package bongo
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	//MenusKey is the key used to store the site menus in the template context.
+	MenusKey = "Menus"
+
+	pageMenu   = "menu"
+	pageWeight = "weight"
+	pageParent = "parent"
+	pageTitle  = "title"
+)
+
+type (
+	//MenuEntry is an item in a navigation menu.
+	MenuEntry struct {
+		// Identifier is used by other entries to refer to this entry as their
+		// parent. Defaults to Name.
+		Identifier string `yaml:"identifier"`
+		Name       string `yaml:"name"`
+		URL        string `yaml:"url"`
+		Weight     int    `yaml:"weight"`
+		Parent     string `yaml:"parent"`
+
+		// Page is the page this entry was defined in, it is nil for entries
+		// defined in the configuration file.
+		Page     *Page `yaml:"-"`
+		Children Menu  `yaml:"-"`
+	}
+
+	//Menu is a list of menu entries sorted by weight.
+	Menu []*MenuEntry
+
+	//Menus are named menus, like main or footer.
+	Menus map[string]Menu
+
+	//Breadcrumb is a link in the path from the root of the site to a page.
+	Breadcrumb struct {
+		Title string
+		URL   string
+	}
+)
+
+// IsActive returns true if the entry points to the page p.
+func (m *MenuEntry) IsActive(p *Page) bool {
+	if p == nil {
+		return false
+	}
+	return m.Page == p || sameURL(m.URL, p.URL())
+}
+
+// HasActive returns true if any descendant of the entry points to the page p.
+func (m *MenuEntry) HasActive(p *Page) bool {
+	for _, c := range m.Children {
+		if c.IsActive(p) || c.HasActive(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasChildren returns true if the entry has nested entries.
+func (m *MenuEntry) HasChildren() bool {
+	return len(m.Children) > 0
+}
+
+func (m Menu) Len() int { return len(m) }
+func (m Menu) Less(i, j int) bool {
+	if m[i].Weight != m[j].Weight {
+		return m[i].Weight < m[j].Weight
+	}
+	return m[i].Name < m[j].Name
+}
+func (m Menu) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+
+func (m Menu) sort() {
+	sort.Sort(m)
+	for _, e := range m {
+		e.Children.sort()
+	}
+}
+
+// newMenus builds the menus defined in the configuration, and the pages which
+// have menu set in their front matter.
+func newMenus(cfg map[string][]*MenuEntry, pages PageList) Menus {
+	// the entries are copied, since building the tree changes them.
+	entries := make(map[string][]*MenuEntry)
+	for name, list := range cfg {
+		for _, e := range list {
+			c := *e
+			c.Children = nil
+			entries[name] = append(entries[name], &c)
+		}
+	}
+	for _, p := range pages {
+		data, ok := p.Data.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, name := range stringList(data[pageMenu]) {
+			e := &MenuEntry{
+				Name:   p.Title(),
+				URL:    p.URL(),
+				Weight: toInt(data[pageWeight]),
+				Page:   p,
+			}
+			e.Parent, _ = data[pageParent].(string)
+			entries[name] = append(entries[name], e)
+		}
+	}
+	menus := make(Menus)
+	for name, list := range entries {
+		menus[name] = menuTree(list)
+	}
+	return menus
+}
+
+// menuTree nests entries under their parents, entries whose parent can't be
+// found are kept at the top level.
+func menuTree(list []*MenuEntry) Menu {
+	ids := make(map[string]*MenuEntry)
+	for _, e := range list {
+		if e.Identifier == "" {
+			e.Identifier = e.Name
+		}
+		ids[e.Identifier] = e
+	}
+	var top Menu
+	for _, e := range list {
+		if parent, ok := ids[e.Parent]; ok && parent != e {
+			parent.Children = append(parent.Children, e)
+			continue
+		}
+		top = append(top, e)
+	}
+	top.sort()
+	return top
+}
+
+// Breadcrumbs returns links to the sections the page is in, starting from the
+// top level section and ending with the page itself.
+func (p *Page) Breadcrumbs() []*Breadcrumb {
+	var rst []*Breadcrumb
+	if p.sec != nil {
+		for _, s := range append(p.sec.Ancestors(), p.sec) {
+			if s.IsRoot() {
+				continue
+			}
+			rst = append(rst, &Breadcrumb{Title: s.Title(), URL: s.URL()})
+		}
+		if p.sec.Index == p {
+			return rst
+		}
+	}
+	return append(rst, &Breadcrumb{Title: p.Title(), URL: p.URL()})
+}
+
+// sameURL compares urls ignoring the trailing index.html
+func sameURL(a, b string) bool {
+	clean := func(s string) string {
+		return strings.TrimSuffix(strings.TrimSuffix(s, indexPage), "/")
+	}
+	return clean(a) == clean(b)
+}
+
+// stringList returns v as a list of strings, v can be a string or a list.
+func stringList(v interface{}) []string {
+	switch x := v.(type) {
+	case string:
+		return []string{x}
+	case []string:
+		return x
+	case []interface{}:
+		var rst []string
+		for _, i := range x {
+			if s, ok := i.(string); ok {
+				rst = append(rst, s)
+			}
+		}
+		return rst
+	}
+	return nil
+}
+
+func toInt(v interface{}) int {
+	switch x := v.(type) {
+	case int:
+		return x
+	case int64:
+		return int(x)
+	case float64:
+		return int(x)
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package bongo
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+var menusConfig = `
+menus:
+  main:
+    - name: Docs
+      url: /docs/
+      weight: 2
+    - name: Home
+      url: /
+      weight: 1
+`
+
+func TestMenus(t *testing.T) {
+	cfg := DefaultConfig()
+	if err := yaml.Unmarshal([]byte(menusConfig), cfg); err != nil {
+		t.Fatal(err)
+	}
+	pages := PageList{
+		{Path: "site/docs/install.md", Data: map[string]interface{}{
+			"title": "Install", "section": "docs", "menu": "main", "parent": "Docs", "weight": 2,
+		}},
+		{Path: "site/docs/usage.md", Data: map[string]interface{}{
+			"title": "Usage", "section": "docs/guide", "menu": []interface{}{"main", "footer"}, "parent": "Docs", "weight": 1,
+		}},
+	}
+	newSectionTree("site", pages, false)
+	menus := newMenus(cfg.Menus, pages)
+	main := menus["main"]
+	if len(main) != 2 || main[0].Name != "Home" || main[1].Name != "Docs" {
+		t.Fatalf("expected Home and Docs entries got %v", main)
+	}
+	docs := main[1]
+	if len(docs.Children) != 2 || docs.Children[0].Page != pages[1] {
+		t.Fatalf("expected pages to be nested under Docs")
+	}
+	if !docs.Children[1].IsActive(pages[0]) || docs.IsActive(pages[0]) || !docs.HasActive(pages[0]) {
+		t.Error("expected Install to be active")
+	}
+	if len(menus["footer"]) != 1 {
+		t.Errorf("expected a footer menu")
+	}
+
+	crumbs := pages[1].Breadcrumbs()
+	if len(crumbs) != 3 {
+		t.Fatalf("expected 3 breadcrumbs got %d", len(crumbs))
+	}
+	if crumbs[0].URL != "/docs/index.html" || crumbs[1].Title != "guide" || crumbs[2].Title != "Usage" {
+		t.Errorf("unexpected breadcrumbs %v %v %v", crumbs[0], crumbs[1], crumbs[2])
+	}
+}
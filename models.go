@@ -3,11 +3,12 @@ package bongo
 import (
 	"html/template"
 	"io"
-	"io/ioutil"
+	"path"
+	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 	"time"
-
-	"github.com/a8m/mark"
 )
 
 const (
@@ -19,11 +20,19 @@ const (
 	//OutputDir is the name of the directory where generated files are saved
 	OutputDir = "_site"
 
+	//BuildMarker is the file written in the output directory on every build. An
+	// output directory which is not empty is only cleaned when it has the
+	// marker, so files bongo didn't generate are never removed.
+	BuildMarker = ".bongo"
+
 	//DefaultExt is the default extensinon name for output files
 	DefaultExt = ".html"
 
 	//DefaultPerm is the default permissinon for generated files
-	DefaultPerm = 0600
+	DefaultPerm = 0644
+
+	//DefaultDirPerm is the default permission for generated directories
+	DefaultDirPerm = 0755
 
 	//DefaultPageKey is the key used to store current page in template
 	// context data.
@@ -36,6 +45,22 @@ const (
 	//AllSectionsKey is the key used to store all sections in the template context data
 	AllSectionsKey = "Sections"
 
+	//SectionKey is the key used to store the current *Section in the template context
+	SectionKey = "Section"
+
+	//SectionsModeKey is the configuration key which sets how pages are grouped into
+	// sections. By default the section is set in the front matter, set it to
+	// SectionsFromDirs to use the directory of the page instead.
+	SectionsModeKey = "sections"
+
+	//SectionsFromDirs is the value of SectionsModeKey for taking sections from
+	// directories
+	SectionsFromDirs = "directories"
+
+	//SectionIndexFile is the name of the file that holds the metadata of the section
+	// it is in
+	SectionIndexFile = "_index.md"
+
 	//DefaultConfigFile is the default configuraton file for abongo based project
 	DefaultConfigFile = "_bongo.yml"
 
@@ -77,9 +102,83 @@ type (
 	// Page is a represantation of text document
 	Page struct {
 		Path    string
-		Body    io.Reader
+		Body    []byte
 		ModTime time.Time
 		Data    interface{}
+
+		// Prev and Next are the pages before and after this one, when all
+		// pages in the site are sorted by date.
+		Prev, Next *Page
+
+		// PrevInSection and NextInSection are the pages before and after this
+		// one in its section.
+		PrevInSection, NextInSection *Page
+
+		sec        *Section
+		ctx        *renderContext
+		once       sync.Once
+		out        *pageContent
+		unresolved []string
+		errs       []error
+		deps       []string
+
+		// line is the number of lines before the body in the source file.
+		line int
+
+		// permalink is the url pattern configured for the section of the page.
+		permalink string
+
+		// lang is the language code of the page, key is its path relative to
+		// the project root without the language and prefix is the url prefix
+		// of the language.
+		lang, key, prefix string
+		translations      PageList
+
+		// resources are the files of the page bundle.
+		resources Resources
+
+		// virtual is true for pages without a markdown file, which are
+		// generated by content adapters and plugins.
+		virtual bool
+	}
+
+	//Options are settings for a single build, they are usually set from the
+	// commandline.
+	Options struct {
+		// Strict turns build warnings, like unresolved links into errors.
+		Strict bool
+
+		// Check runs the link checker on the generated site after building.
+		Check        bool
+		CheckOptions CheckOptions
+
+		// Environment selects the configuration file _bongo.<Environment>.yml
+		// which is read on top of _bongo.yml.
+		Environment string
+
+		// Destination is the directory the site is generated in, it overrides
+		// the output setting of the configuration.
+		Destination string
+
+		// Minify minifies the generated pages, even when the minify setting of
+		// the configuration is off.
+		Minify bool
+
+		// Plugins are the plugins registered with App.Use.
+		Plugins []Plugin
+	}
+
+	//Configurable is implemented by Generators which need the build Options
+	// before the Before method is called.
+	Configurable interface {
+		SetOptions(Options)
+	}
+
+	//Destinationer is implemented by Generators which can generate the site
+	// outside of the OutputDir of the project. Destination is called after
+	// Before.
+	Destinationer interface {
+		Destination() string
 	}
 
 	//FileLoader loads files needed for processing.
@@ -110,8 +209,129 @@ type (
 
 //HTML returns body text as html.
 func (p *Page) HTML() template.HTML {
-	b, _ := ioutil.ReadAll(p.Body)
-	return template.HTML(mark.New(string(b), mark.DefaultOptions()).Render())
+	return template.HTML(p.content().html)
+}
+
+//URL returns the path of the generated page relative to the site root.
+func (p *Page) URL() string {
+	if p.sec != nil && p.sec.Index == p {
+		return p.sec.URL()
+	}
+	if p.permalink != "" {
+		return p.prefix + expandPermalink(p.permalink, p)
+	}
+	if p.isBundle() {
+		return p.prefix + "/" + path.Join(p.sectionName(), p.fileName()) + "/"
+	}
+	return p.prefix + "/" + path.Join(p.sectionName(), p.fileName()+DefaultExt)
+}
+
+//Permalink returns the absolute url of the page, which is the URL after the
+// baseURL setting.
+func (p *Page) Permalink() string {
+	if p.ctx == nil {
+		return p.URL()
+	}
+	return absURL(p.ctx.baseURL, p.URL())
+}
+
+// fileName returns the name of the source file, without the extension and the
+// language. It is the name of the directory for page bundles.
+func (p *Page) fileName() string {
+	name := p.key
+	if name == "" {
+		name = filepath.ToSlash(p.Path)
+	}
+	if p.isBundle() {
+		return path.Base(path.Dir(name))
+	}
+	name = path.Base(name)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+//Lang returns the code of the language of the page, it is empty when the site
+// has no languages.
+func (p *Page) Lang() string {
+	return p.lang
+}
+
+//Translations returns the versions of the page in the other languages, sorted
+// by the weight of the languages.
+func (p *Page) Translations() PageList {
+	return p.translations
+}
+
+// translationKey returns the key shared by the translations of the page.
+func (p *Page) translationKey() string {
+	if data, ok := p.Data.(map[string]interface{}); ok {
+		if k, ok := data[pageTranslationKey].(string); ok && k != "" {
+			return k
+		}
+	}
+	if p.key != "" {
+		return p.key
+	}
+	return p.Path
+}
+
+//Title returns the title set in the front matter, or the file name.
+func (p *Page) Title() string {
+	if data, ok := p.Data.(map[string]interface{}); ok {
+		if title, ok := data[pageTitle].(string); ok {
+			return title
+		}
+	}
+	return p.fileName()
+}
+
+//Section returns the section the page belongs to. It is nil before the site
+// is rendered.
+func (p *Page) Section() *Section {
+	return p.sec
+}
+
+func (p *Page) sectionName() string {
+	if p.sec != nil {
+		return p.sec.Path
+	}
+	return p.frontSection()
+}
+
+// frontSection returns the section set in the front matter of the page.
+func (p *Page) frontSection() string {
+	if data, ok := p.Data.(map[string]interface{}); ok {
+		if sec, ok := data[pageSection].(string); ok {
+			sec = strings.Trim(path.Clean("/"+filepath.ToSlash(sec)), "/")
+			if sec != "" {
+				return sec
+			}
+		}
+	}
+	return defaultSection
+}
+
+//Dependencies returns the files other than the source of the page, which are
+// used to render it. They are known after the page is rendered.
+func (p *Page) Dependencies() []string {
+	return p.deps
+}
+
+func (p *Page) addDependency(file string) {
+	for _, v := range p.deps {
+		if v == file {
+			return
+		}
+	}
+	p.deps = append(p.deps, file)
+}
+
+func (p *Page) addUnresolved(link string) {
+	for _, v := range p.unresolved {
+		if v == link {
+			return
+		}
+	}
+	p.unresolved = append(p.unresolved, link)
 }
 
 //
@@ -120,9 +340,18 @@ func (p *Page) HTML() template.HTML {
 //
 //
 
-func (p PageList) Len() int           { return len(p) }
-func (p PageList) Less(i, j int) bool { return p[i].ModTime.Before(p[j].ModTime) }
-func (p PageList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p PageList) Len() int      { return len(p) }
+func (p PageList) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+// Less orders the pages by date, the date in the front matter or the
+// modification time, and pages with the same date by path.
+func (p PageList) Less(i, j int) bool {
+	di, dj := p[i].date(), p[j].date()
+	if !di.Equal(dj) {
+		return di.Before(dj)
+	}
+	return p[i].Path < p[j].Path
+}
 
 // GetAllSections filter the pagelist for any section informations
 // it returns a map of all the sections with the pages matching the
@@ -131,15 +360,7 @@ func GetAllSections(p PageList) map[string]PageList {
 	sections := make(map[string]PageList)
 	for k := range p {
 		page := p[k]
-		data := page.Data.(map[string]interface{})
-		section := defaultSection
-
-		if sec, ok := data[pageSection]; ok {
-			switch sec.(type) {
-			case string:
-				section = sec.(string)
-			}
-		}
+		section := page.frontSection()
 		if sdata, ok := sections[section]; ok {
 			sdata = append(sdata, page)
 			sections[section] = sdata
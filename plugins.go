@@ -0,0 +1,134 @@
+package bongo
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+type (
+	//Plugin adds features to the build without replacing the Generator. Plugins
+	// are registered with App.Use, and their hooks are called in the order the
+	// plugins are registered, at these points of App.Run
+	//
+	//	OnConfig       after the configuration is loaded
+	//	OnPagesLoaded  after the pages are loaded and parsed, the pages returned
+	//	               are the ones rendered, so plugins can add or remove pages
+	//	OnPageRender   for every page, before any page is rendered
+	//	OnPostRender   before a generated file is written, with its path relative
+	//	               to the output directory. The returned content is written
+	//	OnBuildDone    after the site is generated in the output directory
+	//
+	// An error returned by a hook stops the build. Embed PluginBase to implement
+	// only some of the hooks. Plugins which implement ContentAdapter generate
+	// pages too.
+	Plugin interface {
+		Name() string
+		OnConfig(cfg *Config) error
+		OnPagesLoaded(pages PageList) (PageList, error)
+		OnPageRender(p *Page) error
+		OnPostRender(path string, b []byte) ([]byte, error)
+		OnBuildDone(dest string, pages PageList) error
+	}
+
+	//PluginBase implements the hooks of Plugin, doing nothing.
+	PluginBase struct{}
+
+	//ConfigProvider is implemented by Generators which load the project
+	// configuration, so that plugins can see and change it. Config is called
+	// after Before.
+	ConfigProvider interface {
+		Config() *Config
+	}
+
+	// plugins runs the hooks of a list of plugins.
+	plugins []Plugin
+)
+
+//OnConfig does nothing.
+func (PluginBase) OnConfig(*Config) error { return nil }
+
+//OnPagesLoaded returns pages unchanged.
+func (PluginBase) OnPagesLoaded(pages PageList) (PageList, error) { return pages, nil }
+
+//OnPageRender does nothing.
+func (PluginBase) OnPageRender(*Page) error { return nil }
+
+//OnPostRender returns b unchanged.
+func (PluginBase) OnPostRender(path string, b []byte) ([]byte, error) { return b, nil }
+
+//OnBuildDone does nothing.
+func (PluginBase) OnBuildDone(string, PageList) error { return nil }
+
+//Use registers plugins, their hooks run in the order they are registered.
+func (g *App) Use(p ...Plugin) {
+	g.Options.Plugins = append(g.Options.Plugins, p...)
+}
+
+func pluginError(p Plugin, hook string, err error) error {
+	return fmt.Errorf("plugin %s: %s: %v", p.Name(), hook, err)
+}
+
+func (ps plugins) onConfig(cfg *Config) error {
+	for _, p := range ps {
+		if err := p.OnConfig(cfg); err != nil {
+			return pluginError(p, "OnConfig", err)
+		}
+	}
+	return nil
+}
+
+func (ps plugins) onPagesLoaded(pages PageList) (PageList, error) {
+	for _, p := range ps {
+		var err error
+		if pages, err = p.OnPagesLoaded(pages); err != nil {
+			return nil, pluginError(p, "OnPagesLoaded", err)
+		}
+	}
+	return pages, nil
+}
+
+func (ps plugins) onPageRender(page *Page) error {
+	for _, p := range ps {
+		if err := p.OnPageRender(page); err != nil {
+			return pluginError(p, "OnPageRender", err)
+		}
+	}
+	return nil
+}
+
+// onPostRender passes the content b of the file in the output directory dest
+// through the plugins.
+func (ps plugins) onPostRender(dest, file string, b []byte) ([]byte, error) {
+	if len(ps) == 0 {
+		return b, nil
+	}
+	rel, err := filepath.Rel(dest, file)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ps {
+		if b, err = p.OnPostRender(filepath.ToSlash(rel), b); err != nil {
+			return nil, pluginError(p, "OnPostRender", err)
+		}
+	}
+	return b, nil
+}
+
+func (ps plugins) onBuildDone(dest string, pages PageList) error {
+	for _, p := range ps {
+		if err := p.OnBuildDone(dest, pages); err != nil {
+			return pluginError(p, "OnBuildDone", err)
+		}
+	}
+	return nil
+}
+
+// close releases the resources held by the plugins which implement io.Closer.
+func (ps plugins) close() {
+	for _, p := range ps {
+		if c, ok := p.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
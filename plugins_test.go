@@ -0,0 +1,149 @@
+package bongo
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type recordPlugin struct {
+	PluginBase
+	calls []string
+	fail  string
+}
+
+func (r *recordPlugin) Name() string { return "record" }
+
+func (r *recordPlugin) call(hook string) error {
+	if len(r.calls) == 0 || r.calls[len(r.calls)-1] != hook {
+		r.calls = append(r.calls, hook)
+	}
+	if hook == r.fail {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func (r *recordPlugin) OnConfig(cfg *Config) error {
+	cfg.Params = map[string]interface{}{"from": "plugin"}
+	return r.call("OnConfig")
+}
+
+func (r *recordPlugin) OnPagesLoaded(pages PageList) (PageList, error) {
+	var rst PageList
+	for _, p := range pages {
+		if !strings.Contains(p.Path, "draft") {
+			rst = append(rst, p)
+		}
+	}
+	return rst, r.call("OnPagesLoaded")
+}
+
+func (r *recordPlugin) OnPageRender(p *Page) error {
+	p.Data.(map[string]interface{})["title"] = strings.ToUpper(p.Title())
+	return r.call("OnPageRender")
+}
+
+func (r *recordPlugin) OnPostRender(file string, b []byte) ([]byte, error) {
+	if path.Ext(file) == ".html" {
+		b = append(b, "<!-- "+file+" -->"...)
+	}
+	return b, r.call("OnPostRender")
+}
+
+func (r *recordPlugin) OnBuildDone(dest string, pages PageList) error {
+	if err := ioutil.WriteFile(filepath.Join(dest, "pages.txt"), []byte(pages[0].Title()), 0644); err != nil {
+		return err
+	}
+	return r.call("OnBuildDone")
+}
+
+func TestPlugins(t *testing.T) {
+	files := map[string]string{
+		DefaultConfigFile:         "theme: plain\n",
+		"hello.md":                "---\ntitle: Hello\nsection: blog\n---\nHello",
+		"draft.md":                "---\ntitle: Draft\nsection: blog\n---\nDraft",
+		"_themes/plain/post.html": `{{.Page.Title}} {{.Params.from}}`,
+	}
+	dir := writeProject(t, plainTheme(files))
+	defer os.RemoveAll(dir)
+	p := &recordPlugin{}
+	app := New()
+	app.Use(p)
+	err := app.Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"OnConfig", "OnPagesLoaded", "OnPageRender", "OnPostRender", "OnBuildDone"}
+	if strings.Join(p.calls, " ") != strings.Join(expect, " ") {
+		t.Errorf("expected hooks %v got %v", expect, p.calls)
+	}
+	out := filepath.Join(dir, OutputDir)
+	b, err := ioutil.ReadFile(filepath.Join(out, "blog", "hello.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "HELLO plugin<!-- blog/hello.html -->" {
+		t.Errorf("unexpected page %s", b)
+	}
+	if _, err = os.Stat(filepath.Join(out, "blog", "draft.html")); err == nil {
+		t.Error("expected the draft to be removed by the plugin")
+	}
+	if b, _ = ioutil.ReadFile(filepath.Join(out, "pages.txt")); string(b) != "HELLO" {
+		t.Errorf("expected the pages file written by the plugin got %s", b)
+	}
+
+	app = New()
+	app.Use(&recordPlugin{fail: "OnPostRender"})
+	err = app.Run(dir)
+	if err == nil || err.Error() != "plugin record: OnPostRender: failed" {
+		t.Errorf("expected the plugin error got %v", err)
+	}
+}
+
+type signPlugin struct{ PluginBase }
+
+func (signPlugin) Name() string { return "sign" }
+
+func (signPlugin) OnPagesLoaded(pages PageList) (PageList, error) {
+	for _, p := range pages {
+		p.HTML()
+	}
+	return pages, nil
+}
+
+func (signPlugin) OnPageRender(p *Page) error {
+	p.Body = append(p.Body, " signed"...)
+	return nil
+}
+
+func TestOnPageRenderOrder(t *testing.T) {
+	files := map[string]string{
+		"a.md":                    "---\ntitle: A\nsection: blog\n---\nFirst",
+		"b.md":                    "---\ntitle: B\nsection: blog\n---\nSecond",
+		"_themes/plain/post.html": `{{range .CurrentSection}}[{{.Summary}}]{{end}}`,
+	}
+	dir := writeProject(t, plainTheme(files))
+	defer os.RemoveAll(dir)
+	app := New()
+	app.Use(signPlugin{})
+	err := app.Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.html", "b.html"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, OutputDir, "blog", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []string{"First signed", "Second signed"} {
+			if !strings.Contains(string(b), v) {
+				t.Errorf("expected %q in %s got %s", v, name, b)
+			}
+		}
+	}
+}
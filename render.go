@@ -12,7 +12,6 @@ import (
 
 	"github.com/Unknwon/com"
 	"github.com/gernest/gh"
-	"gopkg.in/yaml.v2"
 )
 
 var (
@@ -38,7 +37,7 @@ func newStatus(code int, msg string) *buildStatus {
 }
 
 func init() {
-	defaultTemplates = template.New("bongo")
+	defaultTemplates = template.New("bongo").Funcs(templateFuncs(nil, nil, nil, ""))
 	for _, n := range gh.AssetNames() {
 		if filepath.Ext(n) != ".html" {
 			continue
@@ -58,18 +57,52 @@ func init() {
 
 //DefaultRenderer is the default REnderer implementation
 type DefaultRenderer struct {
-	config map[string]interface{}
-	rendr  *template.Template
-	root   string
+	config  *Config
+	data    map[string]interface{}
+	i18n    map[string]interface{}
+	assets  *assetSet
+	images  *imageSet
+	minify  bool
+	plugins plugins
+	rendr   *template.Template
+	root    string
+	dest    string
+	opts    Options
+}
+
+//SetOptions sets the Options used to load the configuration in Before.
+func (d *DefaultRenderer) SetOptions(o Options) {
+	d.opts = o
 }
 
 // Before loads configurations and prepare rendering stuffs
 func (d *DefaultRenderer) Before(root string) error {
-	cfg, rendr, err := load(root)
+	cfg, rendr, err := load(root, d.opts.Environment)
+	if err != nil {
+		return err
+	}
+	data, err := LoadData(filepath.Join(root, DataDir))
 	if err != nil {
 		return err
 	}
+	i18n, err := LoadData(filepath.Join(root, I18nDir))
+	if err != nil {
+		return err
+	}
+	dest := d.opts.Destination
+	if dest == "" {
+		dest = cfg.Output
+		if !filepath.IsAbs(dest) {
+			dest = filepath.Join(root, dest)
+		}
+	}
+	if err = checkDestination(root, dest); err != nil {
+		return err
+	}
+	d.dest = dest
 	d.config = cfg
+	d.data = data
+	d.i18n = i18n
 	d.rendr = rendr
 	d.root = root
 	return nil
@@ -77,28 +110,117 @@ func (d *DefaultRenderer) Before(root string) error {
 
 // Render builds a static site
 func (d *DefaultRenderer) Render(root string, pages PageList, opts ...interface{}) error {
-	baseInfo, err := os.Stat(root)
-	if err != nil {
+	buildDIr := d.Destination()
+	if err := prepareBuild(root, buildDIr, d.config.DirMode, d.config.FileMode); err != nil {
 		return err
 	}
-	buf := &bytes.Buffer{}
+	o := getOptions(opts)
+	d.minify = d.config.Minify || o.Minify
+	d.plugins = o.Plugins
+
+	langs := d.config.languages()
+	sites := make(map[string]PageList)
+	for _, page := range pages {
+		page.lang, page.key = d.config.pageLanguage(root, page.Path)
+		page.prefix = d.config.langPrefix(page.lang)
+		sites[page.lang] = append(sites[page.lang], page)
+	}
+	trees := make([]*Section, len(langs))
+	for i, lang := range langs {
+		trees[i] = newSectionTree(root, sites[lang.Code], d.config.sectionsFromDirs())
+		trees[i].setPrefix(d.config.langPrefix(lang.Code))
+	}
+	for _, page := range pages {
+		page.permalink = d.config.permalink(page.sectionName())
+		if err := page.loadResources(); err != nil {
+			return err
+		}
+	}
+	for _, tree := range trees {
+		if err := tree.checkURLs(); err != nil {
+			return err
+		}
+	}
+	linkTranslations(pages, langs)
+	d.assets = newAssetSet(root, d.getTheme(), buildDIr, d.writeFile)
+	d.images = newImageSet(root, buildDIr, d.config.Images, d.writeFile)
 
-	buildDIr := filepath.Join(root, OutputDir)
-	if err = prepareBuild(baseInfo, buildDIr); err != nil {
+	ctx, err := newRenderContext(root, d.config, newLinkResolver(root, pages))
+	if err != nil {
 		return err
 	}
+	ctx.images = d.images
+
+	// every language gets its own copy of the templates, with the i18n function
+	// translating to the language. The context of all pages is set before
+	// rendering, since templates can render the translations of a page.
+	tpls := make([]*template.Template, len(langs))
+	for i, lang := range langs {
+		tpl, err := d.rendr.Clone()
+		if err != nil {
+			return err
+		}
+		tpl.Funcs(templateFuncs(&translator{tables: d.i18n, lang: lang.Code, fallback: d.config.DefaultLanguage}, d.assets, d.images, d.config.BaseURL))
+		lctx := *ctx
+		lctx.shortcodes = &shortcodeSet{theme: d.getTheme(), tpl: tpl}
+		for _, page := range sites[lang.Code] {
+			page.ctx = &lctx
+		}
+		tpls[i] = tpl
+	}
+
+	// the plugins see every page before any template is executed, since a
+	// template can render other pages. Content rendered before, like in
+	// OnPagesLoaded, is rendered again with the changes.
+	for _, page := range pages {
+		if err = d.plugins.onPageRender(page); err != nil {
+			return err
+		}
+		page.resetContent()
+	}
+
+	for i, lang := range langs {
+		data := map[string]interface{}{
+			LanguageKey:   lang,
+			LanguagesKey:  langs,
+			MenusKey:      newMenus(d.config.Menus, sites[lang.Code]),
+			DataKey:       d.data,
+			SiteConfigKey: d.config.site,
+			ParamsKey:     d.config.Params,
+		}
+		if err = d.renderSite(tpls[i], trees[i], data, o); err != nil {
+			return err
+		}
+		if err = d.writeSearchIndex(lang.Code, sites[lang.Code]); err != nil {
+			return err
+		}
+	}
+	if d.assets.err != nil {
+		return d.assets.err
+	}
+	return d.images.err
+}
+
+// renderSite writes the pages of the sections in tree, the section indexes and
+// the home page. data has the template context shared by all the pages.
+func (d *DefaultRenderer) renderSite(tpl *template.Template, tree *Section, shared map[string]interface{}, o Options) error {
+	buf := &bytes.Buffer{}
+	buildDIr := d.Destination()
 	themeName := d.getTheme()
 
-	allsections := GetAllSections(pages)
-	for key := range allsections {
-		setionPages := allsections[key]
-		view := DefaultView
+	allsections := tree.sectionMap()
+	for _, sec := range tree.Sections() {
 		data := make(map[string]interface{})
+		for k, v := range shared {
+			data[k] = v
+		}
 
-		data[CurrentSectionKey] = setionPages
+		data[CurrentSectionKey] = sec.Pages
 		data[AllSectionsKey] = allsections
+		data[SectionKey] = sec
 
-		for _, page := range setionPages {
+		for _, page := range sec.Pages {
+			view := DefaultView
 			switch page.Data.(type) {
 			case map[string]interface{}:
 				if v, ok := page.Data.(map[string]interface{})[DefaultView]; ok {
@@ -108,34 +230,52 @@ func (d *DefaultRenderer) Render(root string, pages PageList, opts ...interface{
 			data[DefaultPageKey] = page
 
 			buf.Reset()
-			rerr := d.rendr.ExecuteTemplate(buf, fmt.Sprintf("%s/%s.html", themeName, view), data)
+			rerr := tpl.ExecuteTemplate(buf, fmt.Sprintf("%s/%s.html", themeName, view), data)
 			if rerr != nil {
-				break
+				return fmt.Errorf("%s: %v", page.Path, rerr)
+			}
+			if err := checkPage(page, o); err != nil {
+				return err
 			}
 
-			destFileName := strings.Replace(filepath.Base(page.Path), filepath.Ext(page.Path), DefaultExt, -1)
-			destDir := filepath.Join(buildDIr, key)
-			os.MkdirAll(destDir, baseInfo.Mode())
-			destFile := filepath.Join(destDir, destFileName)
-
-			ioerr := ioutil.WriteFile(destFile, buf.Bytes(), DefaultPerm)
+			destFile := filepath.Join(buildDIr, urlFile(page.URL()))
+			ioerr := d.writeOutput(destFile, buf.Bytes())
 			if ioerr != nil {
-				break
+				return ioerr
+			}
+			if err := d.copyResources(page); err != nil {
+				return err
 			}
 
 		}
 
+		// the index of the root section is the home page.
+		if sec.IsRoot() {
+			continue
+		}
+
 		// write the index page for the section.
 		buf.Reset()
 
-		rerr := d.rendr.ExecuteTemplate(buf, filepath.Join(themeName, DefaultTpl.Index), data)
+		delete(data, DefaultPageKey)
+		if sec.Index != nil {
+			data[DefaultPageKey] = sec.Index
+		}
+		rerr := tpl.ExecuteTemplate(buf, filepath.Join(themeName, DefaultTpl.Index), data)
 		if rerr != nil {
-			return rerr
+			where := "section " + sec.Path
+			if sec.Index != nil {
+				where = sec.Index.Path
+			}
+			return fmt.Errorf("%s: %v", where, rerr)
 		}
-		os.MkdirAll(filepath.Join(buildDIr, key), baseInfo.Mode())
-
-		destIndexFile := filepath.Join(buildDIr, key, indexPage)
-		ioerr := ioutil.WriteFile(destIndexFile, buf.Bytes(), DefaultPerm)
+		if sec.Index != nil {
+			if err := checkPage(sec.Index, o); err != nil {
+				return err
+			}
+		}
+		destIndexFile := filepath.Join(buildDIr, urlFile(sec.URL()))
+		ioerr := d.writeOutput(destIndexFile, buf.Bytes())
 		if ioerr != nil {
 			return ioerr
 		}
@@ -146,24 +286,68 @@ func (d *DefaultRenderer) Render(root string, pages PageList, opts ...interface{
 	buf.Reset()
 
 	data := make(map[string]interface{})
+	for k, v := range shared {
+		data[k] = v
+	}
 	data[AllSectionsKey] = allsections
-	data[SiteConfigKey] = d.config
+	data[SectionKey] = tree
+	if tree.Index != nil {
+		data[DefaultPageKey] = tree.Index
+	}
 
-	rerr := d.rendr.ExecuteTemplate(buf, filepath.Join(themeName, DefaultTpl.Home), data)
+	rerr := tpl.ExecuteTemplate(buf, filepath.Join(themeName, DefaultTpl.Home), data)
 	if rerr != nil {
-		return rerr
+		where := "home"
+		if tree.Index != nil {
+			where = tree.Index.Path
+		}
+		return fmt.Errorf("%s: %v", where, rerr)
+	}
+	if tree.Index != nil {
+		if err := checkPage(tree.Index, o); err != nil {
+			return err
+		}
 	}
 
-	homePage := filepath.Join(buildDIr, indexPage)
-	ioerr := ioutil.WriteFile(homePage, buf.Bytes(), DefaultPerm)
+	homePage := filepath.Join(buildDIr, urlFile(tree.URL()))
+	ioerr := d.writeOutput(homePage, buf.Bytes())
 	if ioerr != nil {
 		return ioerr
 	}
 	return nil
 }
 
+// checkPage returns the first error found when rendering the page content,
+// and logs warnings. Warnings are errors in strict mode.
+func checkPage(page *Page, o Options) error {
+	page.content()
+	if len(page.errs) > 0 {
+		return page.errs[0]
+	}
+	for _, link := range page.unresolved {
+		if o.Strict {
+			return fmt.Errorf("%s: unresolved link %s", page.Path, link)
+		}
+		log.Printf("WARNING %s: unresolved link %s\n", page.Path, link)
+	}
+	return nil
+}
+
+// getOptions returns the build Options passed among the opts to Render.
+func getOptions(opts []interface{}) Options {
+	for _, v := range opts {
+		switch o := v.(type) {
+		case Options:
+			return o
+		case *Options:
+			return *o
+		}
+	}
+	return Options{}
+}
+
 func (d *DefaultRenderer) getTheme() string {
-	return d.config[ThemeKey].(string)
+	return d.config.Theme
 }
 
 // After copies relevant static files to the generated site
@@ -171,25 +355,40 @@ func (d *DefaultRenderer) After(root string) error {
 	return d.copyStatic()
 }
 
+// copyResources copies the resources of a page bundle next to the generated
+// page.
+func (d *DefaultRenderer) copyResources(p *Page) error {
+	for _, r := range p.Resources() {
+		b, err := ioutil.ReadFile(r.Path)
+		if err != nil {
+			return err
+		}
+		if err = d.writeFile(filepath.Join(d.Destination(), filepath.FromSlash(r.URL())), b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *DefaultRenderer) copyStatic() error {
 	theme := d.getTheme()
-	out := d.getOutputDir()
+	out := d.Destination()
 	switch theme {
 	case defaultTheme:
-		info, _ := os.Stat(d.root)
 		for _, f := range gh.AssetNames() {
 			if filepath.Ext(f) == ".html" {
 				continue
 			}
-			base := filepath.Join(out, filepath.Dir(f))
-			os.MkdirAll(base, info.Mode())
+			if d.assets.isPublished(filepath.Join(out, f)) {
+				continue
+			}
 			b, err := gh.Asset(f)
 			if err != nil {
 				return err
 			}
-			err = ioutil.WriteFile(filepath.Join(out, f), b, DefaultPerm)
+			err = d.writeFile(filepath.Join(out, f), b)
 			if err != nil {
-				log.Println(err, base)
+				log.Println(err, f)
 				return err
 			}
 		}
@@ -198,7 +397,7 @@ func (d *DefaultRenderer) copyStatic() error {
 		// we copy the static directory in the current theme directory
 		staticDir := filepath.Join(d.root, ThemeDir, theme, StaticDir)
 		if com.IsExist(staticDir) {
-			err := com.CopyDir(staticDir, filepath.Join(d.root, OutputDir, StaticDir))
+			err := d.copyDir(staticDir, filepath.Join(out, StaticDir))
 			if err != nil {
 				return err
 			}
@@ -207,58 +406,156 @@ func (d *DefaultRenderer) copyStatic() error {
 	}
 
 	// if we have the static set on the config file we use it.
-	if staticDirective, ok := d.config[StaticDir]; ok {
-		switch staticDirective.(type) {
-		case []interface{}:
-			sd := staticDirective.([]interface{})
-			for _, f := range sd {
-				dir := f.(string)
-				err := com.CopyDir(filepath.Join(d.root, dir), filepath.Join(out, dir))
-				if err != nil {
-					return err
-				}
-			}
+	for _, dir := range d.config.Static {
+		err := d.copyDir(filepath.Join(d.root, dir), filepath.Join(out, dir))
+		if err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func (d *DefaultRenderer) getOutputDir() string {
-	return filepath.Join(d.root, OutputDir)
+//Destination returns the directory the site is generated in. It is known after
+// Before is called.
+func (d *DefaultRenderer) Destination() string {
+	return d.dest
+}
+
+//Config returns the configuration of the project. It is known after Before is
+// called.
+func (d *DefaultRenderer) Config() *Config {
+	return d.config
+}
+
+// writeFile writes b to file in the output directory, with the configured
+// permissions.
+func (d *DefaultRenderer) writeFile(file string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), d.config.DirMode); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, d.config.FileMode)
+}
+
+// writeOutput writes the rendered output b to file, after passing it through
+// the plugins. It is minified first when minification is turned on.
+func (d *DefaultRenderer) writeOutput(file string, b []byte) error {
+	b, err := d.plugins.onPostRender(d.Destination(), file, b)
+	if err != nil {
+		return err
+	}
+	if d.minify {
+		if b, err = minifyFile(file, b); err != nil {
+			return err
+		}
+	}
+	return d.writeFile(file, b)
+}
+
+// copyDir copies the files in the directory src to dst, with the configured
+// permissions.
+func (d *DefaultRenderer) copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), d.config.DirMode)
+		}
+		if d.assets.isPublished(filepath.Join(dst, rel)) {
+			return nil
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return d.writeFile(filepath.Join(dst, rel), b)
+	})
 }
 
 //NewDefaultRenderer returns default Renderer implementation
 func NewDefaultRenderer() *DefaultRenderer {
-	return &DefaultRenderer{config: make(map[string]interface{})}
+	return &DefaultRenderer{config: DefaultConfig()}
+}
+
+// prepareBuild empties the output directory buildDir of the project at root,
+// or creates it, and marks it as generated by bongo.
+func prepareBuild(root, buildDir string, dirMode, fileMode os.FileMode) error {
+	if err := cleanBuild(root, buildDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(buildDir, dirMode); err != nil {
+		return fmt.Errorf("creating %s %v", buildDir, err)
+	}
+	marker := []byte("generated by bongo, the files in this directory are removed on every build\n")
+	return ioutil.WriteFile(filepath.Join(buildDir, BuildMarker), marker, fileMode)
 }
 
-func prepareBuild(baseInfo os.FileInfo, buildDir string) error {
-	// If there is already a built project we remove it and start afresh
-	info, err := os.Stat(buildDir)
+// cleanBuild removes the files in the output directory buildDir of the project
+// at root, when it has the BuildMarker of a previous build. The default output
+// directory is cleaned without the marker too, since the builds of bongo
+// versions before the marker left it there. It is an error when another
+// directory has files but no marker.
+func cleanBuild(root, buildDir string) error {
+	f, err := os.Open(buildDir)
 	if err != nil {
 		if os.IsNotExist(err) {
-			oerr := os.MkdirAll(buildDir, baseInfo.Mode())
-			if oerr != nil {
-				return fmt.Errorf("create build dir at %s %v", buildDir, err)
-			}
-		}
-	} else {
-		oerr := os.RemoveAll(buildDir)
-		if oerr != nil {
-			return fmt.Errorf("cleaning %s %v", buildDir, oerr)
+			return nil
 		}
-		oerr = os.MkdirAll(buildDir, info.Mode())
-		if oerr != nil {
-			return fmt.Errorf("creating %s %v", buildDir, oerr)
+		return err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil || len(names) == 0 {
+		return err
+	}
+	if _, err = os.Stat(filepath.Join(buildDir, BuildMarker)); err != nil && !isDefaultOutput(root, buildDir) {
+		return fmt.Errorf("output directory %s is not empty and was not generated by bongo, empty it or use another one", buildDir)
+	}
+	for _, name := range names {
+		if err = os.RemoveAll(filepath.Join(buildDir, name)); err != nil {
+			return fmt.Errorf("cleaning %s %v", buildDir, err)
 		}
 	}
 	return nil
 }
 
-//Rollback delets the build directory
+// isDefaultOutput returns true if buildDir is the default output directory of
+// the project at root.
+func isDefaultOutput(root, buildDir string) bool {
+	a, err := filepath.Abs(buildDir)
+	if err != nil {
+		return false
+	}
+	b, err := filepath.Abs(filepath.Join(root, OutputDir))
+	return err == nil && a == b
+}
+
+//Rollback removes the files of the build in the default output directory of
+// the project at root.
 func Rollback(root string) {
-	buildDIr := filepath.Join(root, OutputDir)
-	os.RemoveAll(buildDIr)
+	cleanBuild(root, filepath.Join(root, OutputDir))
+}
+
+// checkDestination returns an error if the output directory dest is the
+// project root or contains it, since it is removed on every build.
+func checkDestination(root, dest string) error {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	absDest, err := filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(absDest, absRoot)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("output directory %s contains the project %s", dest, root)
+	}
+	return nil
 }
 
 func loadTheme(base, name string, tpl *template.Template) error {
@@ -285,35 +582,17 @@ func loadTheme(base, name string, tpl *template.Template) error {
 
 }
 
-func load(root string) (map[string]interface{}, *template.Template, error) {
-	cfg := loadConfig(root)
-	tpl := template.New("bongo")
-	if cfg != nil {
-		if tName, ok := cfg[ThemeKey]; ok {
-			terr := loadTheme(root, tName.(string), tpl)
-			if terr != nil {
-				return nil, nil, terr
-			}
-			return cfg, tpl, nil
-		}
-		cfg[ThemeKey] = defaultTheme
-		return cfg, defaultTemplates, nil
-	}
-	c := make(map[string]interface{})
-	c[ThemeKey] = defaultTheme
-	return c, defaultTemplates, nil
-}
-
-func loadConfig(root string) map[string]interface{} {
-	configPath := filepath.Join(root, DefaultConfigFile)
-	b, err := ioutil.ReadFile(configPath)
+func load(root, env string) (*Config, *template.Template, error) {
+	cfg, err := LoadConfig(root, env)
 	if err != nil {
-		return nil
+		return nil, nil, err
 	}
-	m := make(map[string]interface{})
-	err = yaml.Unmarshal(b, m)
-	if err != nil {
-		log.Fatalf("loading config %v \n", err)
+	if !com.IsDir(filepath.Join(root, ThemeDir, cfg.Theme)) {
+		return cfg, defaultTemplates, nil
+	}
+	tpl := template.New("bongo").Funcs(templateFuncs(nil, nil, nil, ""))
+	if err = loadTheme(root, cfg.Theme, tpl); err != nil {
+		return nil, nil, err
 	}
-	return m
+	return cfg, tpl, nil
 }
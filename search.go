@@ -0,0 +1,100 @@
+package bongo
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	//SearchKey is the configuration key for the search index settings
+	SearchKey = "search"
+
+	//DefaultSearchFile is the name of the search index in the output directory
+	DefaultSearchFile = "search.json"
+
+	pageSearch = "search"
+	pageTags   = "tags"
+)
+
+type (
+	//SearchConfig are the settings of the search index, a json file with the
+	// text of all pages for client side search libraries like lunr.js or
+	// Fuse.js. The index is only written when Enable is set.
+	SearchConfig struct {
+		Enable bool `yaml:"enable"`
+
+		// File is the path of the index in the output directory. Sites with
+		// languages get an index in the directory of every language.
+		File string `yaml:"file"`
+
+		// Words is the number of words of the page text in the index, all the
+		// text is kept when it is zero.
+		Words int `yaml:"words"`
+	}
+
+	// searchDocument is a page in the search index.
+	searchDocument struct {
+		Title    string   `json:"title"`
+		URL      string   `json:"url"`
+		Section  string   `json:"section"`
+		Tags     []string `json:"tags,omitempty"`
+		Headings []string `json:"headings,omitempty"`
+		Body     string   `json:"body"`
+
+		page *Page
+	}
+)
+
+// searchDocuments returns the documents of the search index for pages, except
+// those with search: false in the front matter. words limits the length of the
+// body.
+func searchDocuments(pages PageList, words int) []*searchDocument {
+	rst := []*searchDocument{}
+	for _, p := range pages {
+		data, _ := p.Data.(map[string]interface{})
+		if v, ok := data[pageSearch].(bool); ok && !v {
+			continue
+		}
+		doc := &searchDocument{
+			Title:   p.Title(),
+			URL:     p.URL(),
+			Section: p.sectionName(),
+			Tags:    stringList(data[pageTags]),
+			Body:    p.Plain(),
+			page:    p,
+		}
+		if toc := p.TableOfContents(); toc != nil {
+			doc.Headings = headingTitles(toc.Headings, nil)
+		}
+		if words > 0 {
+			if f := strings.Fields(doc.Body); len(f) > words {
+				doc.Body = strings.Join(f[:words], " ")
+			}
+		}
+		rst = append(rst, doc)
+	}
+	return rst
+}
+
+func headingTitles(headings []*Heading, rst []string) []string {
+	for _, h := range headings {
+		rst = append(rst, h.Title)
+		rst = headingTitles(h.Children, rst)
+	}
+	return rst
+}
+
+// writeSearchIndex writes the search index of pages, in the directory of the
+// language code.
+func (d *DefaultRenderer) writeSearchIndex(code string, pages PageList) error {
+	if !d.config.Search.Enable {
+		return nil
+	}
+	b, err := json.Marshal(searchDocuments(pages, d.config.Search.Words))
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(d.Destination(), filepath.FromSlash(d.config.langPrefix(code)))
+	return d.writeOutput(filepath.Join(dir, filepath.FromSlash(d.config.Search.File)), b)
+}
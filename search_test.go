@@ -0,0 +1,65 @@
+package bongo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSearchIndex(t *testing.T) {
+	files := map[string]string{
+		DefaultConfigFile: "theme: plain\nsearch:\n  enable: true\n  words: 4\nlanguages:\n  en:\n    weight: 1\n  sw:\n    weight: 2\n",
+		"install.md":      "---\ntitle: Install\nsection: docs\ntags: [setup, go]\n---\n# Download\n\nGet the binary from the releases page.\n\n## Build\n\nOr build it.\n",
+		"secret.md":       "---\ntitle: Secret\nsearch: false\n---\nHidden",
+		"install.sw.md":   "---\ntitle: Kusakinisha\nsection: docs\n---\nPakua programu.\n",
+	}
+	dir := writeProject(t, plainTheme(files))
+	defer os.RemoveAll(dir)
+	err := New().Run(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string][]*searchDocument{
+		"search.json": {{
+			Title:    "Install",
+			URL:      "/docs/install.html",
+			Section:  "docs",
+			Tags:     []string{"setup", "go"},
+			Headings: []string{"Download", "Build"},
+			Body:     "Download Get the binary",
+		}},
+		"sw/search.json": {{
+			Title:   "Kusakinisha",
+			URL:     "/sw/docs/install.html",
+			Section: "docs",
+			Body:    "Pakua programu.",
+		}},
+	}
+	for name, v := range expect {
+		b, err := ioutil.ReadFile(filepath.Join(dir, OutputDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var docs []*searchDocument
+		if err = json.Unmarshal(b, &docs); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(docs, v) {
+			t.Errorf("unexpected index %s got %s", name, b)
+		}
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, DefaultConfigFile), []byte("theme: plain\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = New().Run(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, OutputDir, "search.json")); !os.IsNotExist(err) {
+		t.Errorf("expected no search index unless it is enabled got %v", err)
+	}
+}
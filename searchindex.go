@@ -0,0 +1,368 @@
+package bongo
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/blevesearch/go-porterstemmer"
+)
+
+//SearchPath is the path of the search endpoint of the development server
+const SearchPath = "/_bongo/search"
+
+const (
+	// the fields of a page in the search index, and their weight when ranking
+	// results.
+	fieldTitle = iota
+	fieldHeadings
+	fieldBody
+	numFields
+
+	// headingGap separates the positions of headings, so that phrases don't
+	// match across two headings.
+	headingGap = 100
+
+	defaultSearchLimit = 20
+	snippetWords       = 30
+)
+
+var fieldWeights = [numFields]float64{fieldTitle: 10, fieldHeadings: 5, fieldBody: 1}
+
+type (
+	//SearchIndex is an in memory full text index of the pages of a site. Words
+	// are stemmed, so searching for install finds installing, and quoted phrases
+	// match words next to each other. Results are ranked by where the words are
+	// found, matches in the title count more than matches in headings, which
+	// count more than matches in the text.
+	//
+	// SearchIndex is a http.Handler, which answers queries in the q parameter
+	// with json results.
+	SearchIndex struct {
+		mu    sync.RWMutex
+		docs  map[string]*indexedPage
+		terms map[string]map[string]*posting
+	}
+
+	//SearchResult is a page matching a query.
+	SearchResult struct {
+		Title   string  `json:"title"`
+		URL     string  `json:"url"`
+		Section string  `json:"section"`
+		Score   float64 `json:"score"`
+		Snippet string  `json:"snippet"`
+	}
+
+	// indexedPage is a page in the index. sum identifies its content, so that
+	// pages which didn't change are not indexed again.
+	indexedPage struct {
+		doc   *searchDocument
+		sum   string
+		terms []string
+	}
+
+	// posting holds the positions of a term in the fields of a page.
+	posting [numFields][]int
+
+	// searchQuery is a parsed query, every term and phrase must match.
+	searchQuery struct {
+		terms   []string
+		phrases [][]string
+		words   map[string]bool
+	}
+)
+
+//NewSearchIndex returns an empty search index.
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		docs:  make(map[string]*indexedPage),
+		terms: make(map[string]map[string]*posting),
+	}
+}
+
+//Update indexes pages, which are the pages of the last build. Pages which
+// changed since the previous update are indexed again, and pages which are
+// gone are removed. Pages with search: false in the front matter are left out.
+func (s *SearchIndex) Update(pages PageList) {
+	docs := searchDocuments(pages, 0)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]bool)
+	for _, doc := range docs {
+		seen[doc.URL] = true
+		b, _ := json.Marshal(doc)
+		sum := fmt.Sprintf("%x", sha256.Sum256(b))
+		if old, ok := s.docs[doc.URL]; ok {
+			if old.sum == sum {
+				old.doc = doc
+				continue
+			}
+			s.remove(doc.URL)
+		}
+		s.add(doc, sum)
+	}
+	for u := range s.docs {
+		if !seen[u] {
+			s.remove(u)
+		}
+	}
+}
+
+//Len returns the number of pages in the index.
+func (s *SearchIndex) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.docs)
+}
+
+func (s *SearchIndex) add(doc *searchDocument, sum string) {
+	p := &indexedPage{doc: doc, sum: sum}
+	postings := make(map[string]*posting)
+	index := func(field int, text string, start int) int {
+		terms := tokenize(text)
+		for i, t := range terms {
+			ps, ok := postings[t]
+			if !ok {
+				ps = &posting{}
+				postings[t] = ps
+			}
+			ps[field] = append(ps[field], start+i)
+		}
+		return start + len(terms)
+	}
+	index(fieldTitle, doc.Title, 0)
+	pos := 0
+	for _, h := range doc.Headings {
+		pos = index(fieldHeadings, h, pos) + headingGap
+	}
+	index(fieldBody, doc.Body, 0)
+	for t, ps := range postings {
+		m, ok := s.terms[t]
+		if !ok {
+			m = make(map[string]*posting)
+			s.terms[t] = m
+		}
+		m[doc.URL] = ps
+		p.terms = append(p.terms, t)
+	}
+	s.docs[doc.URL] = p
+}
+
+func (s *SearchIndex) remove(u string) {
+	p, ok := s.docs[u]
+	if !ok {
+		return
+	}
+	for _, t := range p.terms {
+		delete(s.terms[t], u)
+		if len(s.terms[t]) == 0 {
+			delete(s.terms, t)
+		}
+	}
+	delete(s.docs, u)
+}
+
+//Search returns at most limit pages matching the query q, best matches first.
+// Words in double quotes are matched as a phrase.
+func (s *SearchIndex) Search(q string, limit int) []*SearchResult {
+	query := parseSearchQuery(q)
+	if len(query.terms) == 0 && len(query.phrases) == 0 {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	scores := make(map[string]float64)
+	first := true
+	match := func(found map[string]float64) {
+		for u, v := range scores {
+			if w, ok := found[u]; ok {
+				scores[u] = v + w
+			} else {
+				delete(scores, u)
+			}
+		}
+		if first {
+			scores, first = found, false
+		}
+	}
+	for _, t := range query.terms {
+		found := make(map[string]float64)
+		idf := s.idf(t)
+		for u, ps := range s.terms[t] {
+			for f := 0; f < numFields; f++ {
+				found[u] += fieldWeights[f] * float64(len(ps[f])) * idf
+			}
+		}
+		match(found)
+	}
+	for _, phrase := range query.phrases {
+		found := make(map[string]float64)
+		var idf float64
+		for _, t := range phrase {
+			idf += s.idf(t)
+		}
+		for u := range s.terms[phrase[0]] {
+			for f := 0; f < numFields; f++ {
+				if n := s.phraseCount(u, phrase, f); n > 0 {
+					found[u] += fieldWeights[f] * float64(n) * idf
+				}
+			}
+		}
+		match(found)
+	}
+
+	var rst []*SearchResult
+	for u, score := range scores {
+		doc := s.docs[u].doc
+		rst = append(rst, &SearchResult{
+			Title:   doc.Title,
+			URL:     doc.URL,
+			Section: doc.Section,
+			Score:   math.Round(score*1000) / 1000,
+			Snippet: snippet(doc.Body, query.words),
+		})
+	}
+	sort.Sort(byScore(rst))
+	if limit > 0 && len(rst) > limit {
+		rst = rst[:limit]
+	}
+	return rst
+}
+
+// idf is the inverse document frequency of the term, rare terms weigh more.
+func (s *SearchIndex) idf(t string) float64 {
+	return math.Log(1 + float64(len(s.docs))/float64(1+len(s.terms[t])))
+}
+
+// phraseCount returns the number of times the terms of phrase follow each
+// other in the field of the page u.
+func (s *SearchIndex) phraseCount(u string, phrase []string, field int) int {
+	var n int
+	for _, start := range s.terms[phrase[0]][u][field] {
+		ok := true
+		for i, t := range phrase[1:] {
+			ps, found := s.terms[t][u]
+			if !found || !containsInt(ps[field], start+i+1) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+func containsInt(list []int, v int) bool {
+	i := sort.SearchInts(list, v)
+	return i < len(list) && list[i] == v
+}
+
+// ServeHTTP answers the query in the q parameter, with at most limit results.
+func (s *SearchIndex) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "bad limit "+v, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	results := s.Search(q, limit)
+	if results == nil {
+		results = []*SearchResult{}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":   q,
+		"results": results,
+	})
+}
+
+// parseSearchQuery splits q into terms and quoted phrases. words are the stems
+// of all of them, for highlighting snippets.
+func parseSearchQuery(q string) *searchQuery {
+	query := &searchQuery{words: make(map[string]bool)}
+	parts := strings.Split(q, `"`)
+	for i, part := range parts {
+		terms := tokenize(part)
+		for _, t := range terms {
+			query.words[t] = true
+		}
+		switch {
+		case i%2 == 1 && len(terms) > 1:
+			query.phrases = append(query.phrases, terms)
+		default:
+			query.terms = append(query.terms, terms...)
+		}
+	}
+	return query
+}
+
+// tokenize splits text into lower case, stemmed words.
+func tokenize(text string) []string {
+	var rst []string
+	for _, f := range strings.FieldsFunc(text, notWordRune) {
+		rst = append(rst, porterstemmer.StemString(strings.ToLower(f)))
+	}
+	return rst
+}
+
+func notWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
+
+// snippet returns the words of text around the first one matching words.
+func snippet(text string, words map[string]bool) string {
+	fields := strings.Fields(text)
+	at := 0
+	for i, f := range fields {
+		found := false
+		for _, t := range tokenize(f) {
+			if words[t] {
+				found = true
+			}
+		}
+		if found {
+			at = i
+			break
+		}
+	}
+	start := at - snippetWords/3
+	if start < 0 {
+		start = 0
+	}
+	end := start + snippetWords
+	if end > len(fields) {
+		end = len(fields)
+	}
+	rst := strings.Join(fields[start:end], " ")
+	if start > 0 {
+		rst = "..." + rst
+	}
+	if end < len(fields) {
+		rst += "..."
+	}
+	return rst
+}
+
+type byScore []*SearchResult
+
+func (b byScore) Len() int { return len(b) }
+func (b byScore) Less(i, j int) bool {
+	if b[i].Score != b[j].Score {
+		return b[i].Score > b[j].Score
+	}
+	return b[i].URL < b[j].URL
+}
+func (b byScore) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
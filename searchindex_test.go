@@ -0,0 +1,88 @@
+package bongo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFullTextSearch(t *testing.T) {
+	page := func(name, title, body string, search bool) *Page {
+		data := map[string]interface{}{"title": title, "section": "docs"}
+		if !search {
+			data["search"] = false
+		}
+		return &Page{Path: name, key: name, Data: data, Body: []byte(body)}
+	}
+	pages := PageList{
+		page("install.md", "Installing bongo", "Download the binary.\n\n## Build from source\n\nRun go get to build it.\n", true),
+		page("guide.md", "Guide", "Write posts in markdown, after you install bongo.\n", true),
+		page("secret.md", "Secret", "How to install the secret build.\n", false),
+	}
+	idx := NewSearchIndex()
+	idx.Update(pages)
+	if idx.Len() != 2 {
+		t.Fatalf("expected 2 pages in the index got %d", idx.Len())
+	}
+	urls := func(q string) []string {
+		var rst []string
+		for _, r := range idx.Search(q, 0) {
+			rst = append(rst, r.URL)
+		}
+		return rst
+	}
+	expect := map[string][]string{
+		"install":               {"/docs/install.html", "/docs/guide.html"},
+		"installs Bongo":        {"/docs/install.html", "/docs/guide.html"},
+		`"build from source"`:   {"/docs/install.html"},
+		`"source from build"`:   nil,
+		`markdown "install it"`: nil,
+		"secret":                nil,
+		"":                      nil,
+	}
+	for q, v := range expect {
+		got := urls(q)
+		if len(got) != len(v) {
+			t.Errorf("%s: expected %v got %v", q, v, got)
+			continue
+		}
+		for i := range v {
+			if got[i] != v[i] {
+				t.Errorf("%s: expected %v got %v", q, v, got)
+			}
+		}
+	}
+	if r := idx.Search("markdown", 1); len(r) != 1 || r[0].Snippet != "Write posts in markdown, after you install bongo." {
+		t.Errorf("unexpected results %v", r)
+	}
+
+	// only the pages which changed are indexed again.
+	pages[1] = page("guide.md", "Guide", "Write posts in asciidoc.\n", true)
+	idx.Update(pages[:2])
+	if len(urls("markdown")) != 0 || len(urls("asciidoc")) != 1 {
+		t.Errorf("expected the guide to be indexed again")
+	}
+	idx.Update(pages[:1])
+	if idx.Len() != 1 || len(urls("asciidoc")) != 0 {
+		t.Errorf("expected the guide to be removed from the index")
+	}
+
+	w := httptest.NewRecorder()
+	idx.ServeHTTP(w, httptest.NewRequest("GET", SearchPath+"?q=build", nil))
+	var res struct {
+		Query   string
+		Results []*SearchResult
+	}
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Query != "build" || len(res.Results) != 1 || res.Results[0].Title != "Installing bongo" {
+		t.Errorf("unexpected response %+v", res)
+	}
+	w = httptest.NewRecorder()
+	idx.ServeHTTP(w, httptest.NewRequest("GET", SearchPath+"?q=build&limit=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected bad request got %d", w.Code)
+	}
+}
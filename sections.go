@@ -0,0 +1,234 @@
+package bongo
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// Section is a node in the tree of sections. Sections are relative paths like
+// blog/golang, and every path element is a section on its own.
+type Section struct {
+	// Name is the last element of the section path.
+	Name string
+
+	// Path is the slash separated path of the section, it is empty for the
+	// root section.
+	Path string
+
+	// Index is the page generated from the _index.md file of the section, it
+	// is nil if the section doesn't have one.
+	Index *Page
+
+	Parent   *Section
+	Children []*Section
+	Pages    PageList
+
+	// prefix is the url prefix of the language of the section.
+	prefix string
+}
+
+// Data returns the front matter of the section index page.
+func (s *Section) Data() map[string]interface{} {
+	if s.Index != nil {
+		if data, ok := s.Index.Data.(map[string]interface{}); ok {
+			return data
+		}
+	}
+	return make(map[string]interface{})
+}
+
+// Title returns the title set in the section index page, or the section name.
+func (s *Section) Title() string {
+	if title, ok := s.Data()[pageTitle].(string); ok {
+		return title
+	}
+	return s.Name
+}
+
+// URL returns the url of the index page of the section.
+func (s *Section) URL() string {
+	return s.prefix + "/" + path.Join(s.Path, indexPage)
+}
+
+// IsRoot returns true if s is the root of the sections tree.
+func (s *Section) IsRoot() bool {
+	return s.Parent == nil
+}
+
+// Ancestors returns the parents of the section, starting from the root.
+func (s *Section) Ancestors() []*Section {
+	var rst []*Section
+	for p := s.Parent; p != nil; p = p.Parent {
+		rst = append([]*Section{p}, rst...)
+	}
+	return rst
+}
+
+// Sections returns s and all its descendants.
+func (s *Section) Sections() []*Section {
+	rst := []*Section{s}
+	for _, c := range s.Children {
+		rst = append(rst, c.Sections()...)
+	}
+	return rst
+}
+
+// setPrefix sets the url prefix of s and its descendants.
+func (s *Section) setPrefix(prefix string) {
+	for _, v := range s.Sections() {
+		v.prefix = prefix
+	}
+}
+
+// sectionMap returns the pages of every section except the root, keyed by
+// the section path.
+func (s *Section) sectionMap() map[string]PageList {
+	rst := make(map[string]PageList)
+	for _, v := range s.Sections() {
+		if v.IsRoot() {
+			continue
+		}
+		rst[v.Path] = v.Pages
+	}
+	return rst
+}
+
+// newSectionTree arranges pages into a tree of sections. If fromDirs is true the
+// section of the page is the directory it is in relative to root, otherwise the
+// section is taken from the page front matter. Page bundles are in the section
+// of the directory their directory is in.
+//
+// Pages named _index.md are not added to the section pages, and are used as
+// the section Index instead. When sections are directories, index.md at the
+// root is the Index of the root section, which is the home page. The pages are
+// sorted, and linked to their neighbours in the section and in the whole site.
+func newSectionTree(root string, pages PageList, fromDirs bool) *Section {
+	top := &Section{}
+	all := map[string]*Section{"": top}
+	var get func(string) *Section
+	get = func(name string) *Section {
+		if s, ok := all[name]; ok {
+			return s
+		}
+		parent := get(parentSection(name))
+		s := &Section{Name: path.Base(name), Path: name, Parent: parent}
+		parent.Children = append(parent.Children, s)
+		all[name] = s
+		return s
+	}
+	var site PageList
+	for _, p := range pages {
+		name := p.frontSection()
+		if fromDirs {
+			name = dirSection(root, p.Path)
+			if p.key != "" {
+				name = parentSection(p.key)
+			}
+			if p.isBundle() {
+				name = parentSection(name)
+			}
+		}
+		s := get(name)
+		p.sec = s
+		base := filepath.Base(p.Path)
+		if p.key != "" {
+			base = path.Base(p.key)
+		}
+		switch {
+		case base == SectionIndexFile:
+			if s.Index != nil {
+				// the root index.md is a page when there is an _index.md.
+				s.Pages = append(s.Pages, s.Index)
+				site = append(site, s.Index)
+			}
+			s.Index = p
+			continue
+		case fromDirs && s.IsRoot() && base == BundleIndexFile && s.Index == nil:
+			s.Index = p
+			continue
+		}
+		s.Pages = append(s.Pages, p)
+		site = append(site, p)
+	}
+	for _, s := range all {
+		sort.Stable(s.Pages)
+		sort.Sort(sectionsByPath(s.Children))
+		for i, p := range s.Pages {
+			p.PrevInSection, p.NextInSection = nil, nil
+			if i > 0 {
+				p.PrevInSection = s.Pages[i-1]
+			}
+			if i < len(s.Pages)-1 {
+				p.NextInSection = s.Pages[i+1]
+			}
+		}
+	}
+	sort.Stable(site)
+	for i, p := range site {
+		p.Prev, p.Next = nil, nil
+		if i > 0 {
+			p.Prev = site[i-1]
+		}
+		if i < len(site)-1 {
+			p.Next = site[i+1]
+		}
+	}
+	return top
+}
+
+// checkURLs returns an error when two pages of the tree, or a page and the
+// index of a section, are generated at the same url.
+func (s *Section) checkURLs() error {
+	seen := make(map[string]string)
+	add := func(u, src string) error {
+		file := urlFile(u)
+		if v, ok := seen[file]; ok {
+			return fmt.Errorf("%s and %s are both generated at %s", v, src, u)
+		}
+		seen[file] = src
+		return nil
+	}
+	for _, sec := range s.Sections() {
+		src := "the index of section " + sec.Path
+		switch {
+		case sec.Index != nil:
+			src = sec.Index.Path
+		case sec.IsRoot():
+			src = "the home page"
+		}
+		if err := add(sec.URL(), src); err != nil {
+			return err
+		}
+		for _, p := range sec.Pages {
+			if err := add(p.URL(), p.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dirSection returns the section of a page, based on its directory.
+func dirSection(root, file string) string {
+	rel, err := filepath.Rel(root, filepath.Dir(file))
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+func parentSection(name string) string {
+	p := path.Dir(name)
+	if p == "." || p == "/" {
+		return ""
+	}
+	return p
+}
+
+type sectionsByPath []*Section
+
+func (s sectionsByPath) Len() int           { return len(s) }
+func (s sectionsByPath) Less(i, j int) bool { return s[i].Path < s[j].Path }
+func (s sectionsByPath) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
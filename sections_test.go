@@ -0,0 +1,129 @@
+package bongo
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSectionTree(t *testing.T) {
+	root := "site"
+	page := func(name, section string) *Page {
+		data := map[string]interface{}{}
+		if section != "" {
+			data["section"] = section
+		}
+		return &Page{Path: filepath.Join(root, filepath.FromSlash(name)), Data: data}
+	}
+	pages := PageList{
+		page("index.md", ""),
+		page("docs/_index.md", "docs"),
+		page("docs/guide/install.md", "docs/guide"),
+		page("docs/guide/_index.md", "docs/guide"),
+		page("docs/api/x.md", "blog/golang"),
+	}
+	pages[1].Data.(map[string]interface{})["title"] = "Documentation"
+
+	tree := newSectionTree(root, pages, true)
+	if !tree.IsRoot() || len(tree.Pages) != 0 || tree.Index != pages[0] {
+		t.Fatalf("expected index.md to be the home page, got %d root pages", len(tree.Pages))
+	}
+	if pages[0].URL() != "/index.html" {
+		t.Errorf("expected /index.html got %s", pages[0].URL())
+	}
+	if err := tree.checkURLs(); err != nil {
+		t.Error(err)
+	}
+	if len(tree.Children) != 1 {
+		t.Fatalf("expected 1 child section got %d", len(tree.Children))
+	}
+	docs := tree.Children[0]
+	if docs.Path != "docs" || docs.Title() != "Documentation" || docs.Index != pages[1] {
+		t.Errorf("unexpected docs section %s %s", docs.Path, docs.Title())
+	}
+	if len(docs.Children) != 2 || docs.Children[0].Path != "docs/api" || docs.Children[1].Path != "docs/guide" {
+		t.Fatalf("expected docs/api and docs/guide sections")
+	}
+	guide := docs.Children[1]
+	if len(guide.Pages) != 1 || guide.Pages[0] != pages[2] {
+		t.Errorf("expected install.md in the guide section")
+	}
+	if guide.Index.URL() != "/docs/guide/index.html" {
+		t.Errorf("expected section index url got %s", guide.Index.URL())
+	}
+	ancestors := guide.Ancestors()
+	if len(ancestors) != 2 || ancestors[0] != tree || ancestors[1] != docs {
+		t.Errorf("unexpected ancestors %v", ancestors)
+	}
+	if pages[4].URL() != "/docs/api/x.html" {
+		t.Errorf("expected /docs/api/x.html got %s", pages[4].URL())
+	}
+
+	for _, p := range pages {
+		p.sec = nil
+	}
+	tree = newSectionTree(root, pages, false)
+	m := tree.sectionMap()
+	for _, name := range []string{"home", "docs", "docs/guide", "blog", "blog/golang"} {
+		if _, ok := m[name]; !ok {
+			t.Errorf("expected section %s", name)
+		}
+	}
+	if pages[4].URL() != "/blog/golang/x.html" {
+		t.Errorf("expected /blog/golang/x.html got %s", pages[4].URL())
+	}
+}
+
+func TestSectionURLCollision(t *testing.T) {
+	root := "site"
+	pages := PageList{
+		{Path: filepath.Join(root, "_index.md"), Data: map[string]interface{}{}},
+		{Path: filepath.Join(root, "index.md"), Data: map[string]interface{}{}},
+	}
+	tree := newSectionTree(root, pages, true)
+	if tree.Index != pages[0] {
+		t.Errorf("expected _index.md to be the home page")
+	}
+	err := tree.checkURLs()
+	if err == nil || !strings.Contains(err.Error(), "are both generated at /index.html") {
+		t.Errorf("expected a collision at /index.html got %v", err)
+	}
+}
+
+func TestPageNeighbours(t *testing.T) {
+	now := time.Now()
+	page := func(name, section string, age int) *Page {
+		return &Page{
+			Path:    name,
+			Data:    map[string]interface{}{"section": section},
+			ModTime: now.Add(-time.Duration(age) * time.Hour),
+		}
+	}
+	pages := PageList{
+		page("c.md", "blog", 1),
+		page("a.md", "blog", 3),
+		page("x.md", "docs", 4),
+		page("b.md", "blog", 2),
+	}
+	newSectionTree("", pages, false)
+	a, b, c, x := pages[1], pages[3], pages[0], pages[2]
+	if a.PrevInSection != nil || a.NextInSection != b || b.NextInSection != c || c.NextInSection != nil {
+		t.Error("unexpected order of pages in the blog section")
+	}
+	if x.PrevInSection != nil || x.NextInSection != nil {
+		t.Error("expected x.md to be alone in its section")
+	}
+	if x.Prev != nil || x.Next != a || a.Prev != x || c.Prev != b || c.Next != nil {
+		t.Error("unexpected order of pages in the site")
+	}
+
+	// pages with the same date are sorted by path, and the date in the front
+	// matter is used before the modification time.
+	d, e, f := page("d.md", "notes", 0), page("e.md", "notes", 0), page("f.md", "notes", 0)
+	f.Data.(map[string]interface{})["date"] = "2001-02-03"
+	newSectionTree("", PageList{e, f, d}, false)
+	if f.NextInSection != d || d.NextInSection != e || e.NextInSection != nil {
+		t.Error("unexpected order of pages with the same modification time")
+	}
+}
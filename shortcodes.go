@@ -0,0 +1,400 @@
+package bongo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/a8m/mark"
+)
+
+const (
+	//ShortcodesDir is the directory in a theme where shortcode templates live
+	ShortcodesDir = "shortcodes"
+
+	shortcodeOpen  = "{{<"
+	shortcodeClose = ">}}"
+)
+
+var (
+	shortcodeTag = regexp.MustCompile(`^\{\{<\s*(/?)\s*([\w-]+)(.*?)\s*(/?)>\}\}`)
+
+	builtinShortcodes = template.Must(template.New("shortcodes").Parse(`
+{{define "figure"}}{{$img := .Image (.Get "src")}}<figure{{with .Get "class"}} class="{{.}}"{{end}}><img src="{{if $img}}{{$img.URL}}{{else}}{{.Get "src"}}{{end}}"{{with $img}} srcset="{{.Srcset}}"{{end}}{{with .Get "sizes"}} sizes="{{.}}"{{end}} alt="{{.Get "alt"}}"{{with .Get "title"}} title="{{.}}"{{end}}{{with .Get "width"}} width="{{.}}"{{else}}{{with $img}} width="{{.Width}}" height="{{.Height}}"{{end}}{{end}}>{{with .Get "caption"}}<figcaption>{{.}}</figcaption>{{end}}</figure>{{end}}
+{{define "note"}}<div class="note{{with .Get "type"}} note-{{.}}{{else}}{{with .Get 0}} note-{{.}}{{end}}{{end}}">{{.Markdownify .Inner}}</div>{{end}}
+{{define "include"}}{{.Include}}{{end}}
+{{define "highlight"}}{{.Highlight .Inner (.Get 0) (.Get 1)}}{{end}}
+`))
+)
+
+type (
+	//Shortcode is the template context of a shortcode. For instance the shortcode
+	//
+	//	{{< figure src="logo.png" caption="Our logo" >}}
+	//
+	// is rendered by the template shortcodes/figure.html in the theme, or the
+	// built in figure template.
+	Shortcode struct {
+		Name string
+
+		// Args are the named arguments.
+		Args map[string]string
+
+		// Params are the positional arguments.
+		Params []string
+
+		// Inner is the content between the opening and closing tags of a
+		// paired shortcode. Nested shortcodes are replaced by placeholders,
+		// which are expanded after rendering.
+		Inner string
+
+		Page *Page
+
+		// Line is the line in the source file where the shortcode starts.
+		Line int
+
+		ctx *renderContext
+	}
+
+	// shortcodeSet finds the templates for shortcodes.
+	shortcodeSet struct {
+		theme string
+		tpl   *template.Template
+	}
+
+	// scToken is a piece of the source, it is either text or a shortcode tag.
+	scToken struct {
+		text    string
+		pos     int
+		name    string
+		args    string
+		closing bool
+		single  bool
+	}
+
+	// shortcodeExpander replaces the shortcodes of a page with placeholders,
+	// and keeps their output.
+	shortcodeExpander struct {
+		page *Page
+		ctx  *renderContext
+		out  []string
+	}
+)
+
+// Get returns the argument named key, or the positional argument at index key
+// when key is an integer. It returns an empty string if there is no such
+// argument.
+func (s *Shortcode) Get(key interface{}) string {
+	switch k := key.(type) {
+	case string:
+		return s.Args[k]
+	case int:
+		if k >= 0 && k < len(s.Params) {
+			return s.Params[k]
+		}
+	}
+	return ""
+}
+
+// Markdownify renders src as markdown.
+func (s *Shortcode) Markdownify(src string) template.HTML {
+	return template.HTML(mark.New(src, mark.DefaultOptions()).Render())
+}
+
+// Image returns the local image src, relative to the page or to the project root
+// when it starts with a slash. It returns nil for external urls, missing files
+// and files which are not JPEG, PNG or GIF images.
+func (s *Shortcode) Image(src string) (*Image, error) {
+	if s.ctx == nil || s.ctx.images == nil || src == "" || !HasExt(src, imageExtensions...) {
+		return nil, nil
+	}
+	if u, err := url.Parse(src); err != nil || u.Scheme != "" || u.Host != "" {
+		return nil, nil
+	}
+	name := src
+	if !strings.HasPrefix(src, "/") && s.Page != nil {
+		if rel, err := filepath.Rel(s.ctx.root, filepath.Dir(s.Page.Path)); err == nil {
+			name = path.Join(filepath.ToSlash(rel), src)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(s.ctx.root, filepath.FromSlash(name))); err != nil {
+		return nil, nil
+	}
+	return s.ctx.images.image(name)
+}
+
+// Highlight highlights code, opts are the options used in the info string of
+// fenced code blocks, like "linenos hl_lines=2".
+func (s *Shortcode) Highlight(code, lang, opts string) (template.HTML, error) {
+	code = strings.TrimPrefix(code, "\n")
+	fence := parseFence(strings.Join(append([]string{lang}, strings.Fields(opts)...), fenceSep))
+	if s.ctx == nil || s.ctx.highlight == nil || lang == "" {
+		return template.HTML(plainCode(code, lang)), nil
+	}
+	out, err := s.ctx.highlight.render(code, fence)
+	if err != nil {
+		return "", err
+	}
+	return template.HTML(out), nil
+}
+
+func plainCode(code, lang string) string {
+	var attr string
+	if lang != "" {
+		attr = fmt.Sprintf(` class="lang-%s"`, html.EscapeString(lang))
+	}
+	return fmt.Sprintf("<pre><code%s>%s</code></pre>", attr, html.EscapeString(code))
+}
+
+// lookup returns the template for the shortcode name, templates in the theme
+// take precedence over the built in ones.
+func (s *shortcodeSet) lookup(name string) *template.Template {
+	if s != nil && s.tpl != nil {
+		if t := s.tpl.Lookup(filepath.ToSlash(filepath.Join(s.theme, ShortcodesDir, name+".html"))); t != nil {
+			return t
+		}
+	}
+	return builtinShortcodes.Lookup(name)
+}
+
+// expand replaces the shortcodes in src with placeholders. The output of the
+// shortcodes is put back by restore, after the markdown is rendered.
+func (e *shortcodeExpander) expand(src string) (string, error) {
+	tokens := lexShortcodes(src)
+	out, _, err := e.expandTokens(src, tokens, nil)
+	return out, err
+}
+
+// expandTokens expands tokens until the closing tag of the shortcode opened by
+// parent, it returns the expanded text and the number of tokens consumed.
+func (e *shortcodeExpander) expandTokens(src string, tokens []*scToken, parent *scToken) (string, int, error) {
+	buf := &bytes.Buffer{}
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok.name == "":
+			buf.WriteString(tok.text)
+			continue
+		case tok.closing:
+			if parent != nil && tok.name == parent.name {
+				return buf.String(), i + 1, nil
+			}
+			return "", 0, e.errorf(src, tok.pos, "unexpected closing tag for %s", tok.name)
+		}
+		sc, err := e.newShortcode(src, tok)
+		if err != nil {
+			return "", 0, err
+		}
+		if !tok.single && hasClosing(tokens[i+1:], tok.name) {
+			inner, n, err := e.expandTokens(src, tokens[i+1:], tok)
+			if err != nil {
+				return "", 0, err
+			}
+			sc.Inner = inner
+			i += n
+		}
+		t := e.ctx.shortcodes.lookup(sc.Name)
+		if t == nil {
+			return "", 0, e.errorf(src, tok.pos, "unknown shortcode %s", sc.Name)
+		}
+		out := &bytes.Buffer{}
+		if err = t.Execute(out, sc); err != nil {
+			return "", 0, e.errorf(src, tok.pos, "%s %v", sc.Name, err)
+		}
+		buf.WriteString(placeholder(len(e.out)))
+		e.out = append(e.out, out.String())
+	}
+	if parent != nil {
+		return "", 0, e.errorf(src, parent.pos, "missing closing tag for %s", parent.name)
+	}
+	return buf.String(), len(tokens), nil
+}
+
+// restore replaces the placeholders in src with the output of the shortcodes.
+// Placeholders of nested shortcodes can be in the output of their parents,
+// so the last ones are replaced first.
+func (e *shortcodeExpander) restore(src string) string {
+	for i := len(e.out) - 1; i >= 0; i-- {
+		p := placeholder(i)
+		src = strings.Replace(src, "<p>"+p+"</p>", e.out[i], -1)
+		src = strings.Replace(src, p, e.out[i], -1)
+	}
+	return src
+}
+
+// placeholder returns the text which stands for the output of the nth
+// shortcode in the markdown source. It is made of letters and digits only so
+// that markdown leaves it alone.
+func placeholder(n int) string {
+	return fmt.Sprintf("bongoshortcode%dx", n)
+}
+
+func (e *shortcodeExpander) newShortcode(src string, tok *scToken) (*Shortcode, error) {
+	sc := &Shortcode{
+		Name: tok.name,
+		Args: make(map[string]string),
+		Page: e.page,
+		Line: e.line(src, tok.pos),
+		ctx:  e.ctx,
+	}
+	args, err := splitArgs(tok.args)
+	if err != nil {
+		return nil, e.errorf(src, tok.pos, "%s %v", tok.name, err)
+	}
+	for _, a := range args {
+		if kv := strings.SplitN(a, "=", 2); len(kv) == 2 && isArgName(kv[0]) {
+			sc.Args[kv[0]] = unquote(kv[1])
+			continue
+		}
+		sc.Params = append(sc.Params, unquote(a))
+	}
+	return sc, nil
+}
+
+// line returns the line in the source file of the offset pos in the page body.
+func (e *shortcodeExpander) line(src string, pos int) int {
+	return e.page.line + strings.Count(src[:pos], "\n") + 1
+}
+
+func (e *shortcodeExpander) errorf(src string, pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", e.page.Path, e.line(src, pos), fmt.Sprintf(format, args...))
+}
+
+// lexShortcodes splits src into text and shortcode tags. Escaped shortcodes
+// like {{</* name */>}} are kept as text, without the comment markers.
+func lexShortcodes(src string) []*scToken {
+	var tokens []*scToken
+	pos := 0
+	text := &bytes.Buffer{}
+	textPos := 0
+	for {
+		i := strings.Index(src[pos:], shortcodeOpen)
+		if i < 0 {
+			text.WriteString(src[pos:])
+			break
+		}
+		start := pos + i
+		text.WriteString(src[pos:start])
+		rest := src[start:]
+		if strings.HasPrefix(strings.TrimLeft(rest[len(shortcodeOpen):], " "), "/*") {
+			if end := strings.Index(rest, "*/"+shortcodeClose); end >= 0 {
+				inner := strings.TrimLeft(rest[len(shortcodeOpen):end], " ")
+				text.WriteString(shortcodeOpen + inner[2:] + shortcodeClose)
+				pos = start + end + len("*/"+shortcodeClose)
+				continue
+			}
+		}
+		m := shortcodeTag.FindStringSubmatch(rest)
+		if m == nil {
+			text.WriteString(shortcodeOpen)
+			pos = start + len(shortcodeOpen)
+			continue
+		}
+		if text.Len() > 0 {
+			tokens = append(tokens, &scToken{text: text.String(), pos: textPos})
+			text.Reset()
+		}
+		tokens = append(tokens, &scToken{
+			pos:     start,
+			name:    m[2],
+			args:    m[3],
+			closing: m[1] == "/",
+			single:  m[4] == "/",
+		})
+		pos = start + len(m[0])
+		textPos = pos
+	}
+	if text.Len() > 0 {
+		tokens = append(tokens, &scToken{text: text.String(), pos: textPos})
+	}
+	return tokens
+}
+
+// hasClosing returns true if tokens have the closing tag for name, at the
+// same nesting level.
+func hasClosing(tokens []*scToken, name string) bool {
+	depth := 0
+	for _, t := range tokens {
+		if t.name != name {
+			continue
+		}
+		switch {
+		case t.closing && depth == 0:
+			return true
+		case t.closing:
+			depth--
+		case !t.single:
+			depth++
+		}
+	}
+	return false
+}
+
+// splitArgs splits the arguments of a shortcode on spaces, keeping quoted
+// strings together.
+func splitArgs(s string) ([]string, error) {
+	var (
+		args  []string
+		cur   []rune
+		quote rune
+	)
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			cur = append(cur, r)
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '`':
+			quote = r
+			cur = append(cur, r)
+		case r == ' ' || r == '\t' || r == '\n':
+			if len(cur) > 0 {
+				args = append(args, string(cur))
+				cur = nil
+			}
+		default:
+			cur = append(cur, r)
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quoted argument")
+	}
+	if len(cur) > 0 {
+		args = append(args, string(cur))
+	}
+	return args, nil
+}
+
+func isArgName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '`') && s[len(s)-1] == s[0] {
+		if s[0] == '"' {
+			if v, err := strconv.Unquote(s); err == nil {
+				return v
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,94 @@
+package bongo
+
+import (
+	"html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var shortcodePost = `Intro {{< badge "new" >}}
+
+{{< figure src="/media/logo.png" caption="Our logo" >}}
+
+{{< note warning >}}
+Be **careful**, see {{< badge "docs" >}}
+{{< /note >}}
+
+{{< include "snippets/hello.md" >}}
+
+{{< highlight go "linenos" >}}
+fmt.Println("hi")
+{{< /highlight >}}
+
+Write {{</* figure src="x.png" */>}} to add a figure.
+`
+
+func TestShortcodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bongo-shortcodes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(filepath.Join(dir, "snippets"), 0755)
+	err = ioutil.WriteFile(filepath.Join(dir, "snippets", "hello.md"), []byte("Hello from a snippet"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := template.Must(template.New("bongo").New("blue/shortcodes/badge.html").Parse(`<span class="badge">{{.Get 0}}</span>`))
+	ctx, err := newRenderContext(dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.shortcodes = &shortcodeSet{theme: "blue", tpl: tpl}
+
+	p := &Page{Path: "post.md", Body: []byte(shortcodePost), ctx: ctx}
+	out := string(p.HTML())
+	if len(p.errs) > 0 {
+		t.Fatal(p.errs[0])
+	}
+	expect := []string{
+		`Intro <span class="badge">new</span>`,
+		`<figure><img src="/media/logo.png" alt=""><figcaption>Our logo</figcaption></figure>`,
+		`<div class="note note-warning">`,
+		`<span class="badge">docs</span>`,
+		`Hello from a snippet`,
+		`&#34;hi&#34;</span>`,
+		`Write {{&lt; figure src=&#34;x.png&#34; &gt;}} to add a figure.`,
+	}
+	for _, v := range expect {
+		if !strings.Contains(out, v) {
+			t.Errorf("expected %s in %s", v, out)
+		}
+	}
+	if strings.Contains(out, "bongoshortcode") {
+		t.Errorf("expected placeholders to be replaced got %s", out)
+	}
+
+	sample := []struct {
+		src, err string
+	}{
+		{"---\n\nline two\n{{< nope >}}", "post.md:6: unknown shortcode nope"},
+		{"{{< figure src=\"x.png >}}", "post.md:3: figure unterminated quoted argument"},
+		{"{{< /note >}}", "post.md:3: unexpected closing tag for note"},
+		{"{{< include \"../secret.md\" >}}", "post.md:3: include"},
+	}
+	for _, v := range sample {
+		p = &Page{Path: "post.md", Body: []byte(v.src), ctx: ctx, line: 2}
+		p.HTML()
+		if len(p.errs) == 0 || !strings.HasPrefix(p.errs[0].Error(), v.err) {
+			t.Errorf("expected error %s got %v", v.err, p.errs)
+		}
+	}
+
+	src := "{{< note >}}\nunclosed"
+	e := &shortcodeExpander{page: &Page{Path: "post.md", line: 2}, ctx: ctx}
+	tokens := lexShortcodes(src)
+	_, _, err = e.expandTokens(src, tokens[1:], tokens[0])
+	if err == nil || err.Error() != "post.md:3: missing closing tag for note" {
+		t.Errorf("expected the line of the unclosed shortcode got %v", err)
+	}
+}
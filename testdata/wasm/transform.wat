@@ -0,0 +1,100 @@
+;; assembled with wat2wasm from WABT, without debug names:
+;;
+;;	wat2wasm transform.wat -o transform.wasm
+;;
+;; the bytes of the output are transformModule in wasm_test.go.
+(module
+  (type $unary (func (param i32) (result i32)))
+  (type $transform (func (param i32 i32) (result i64)))
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  ;; bongo_alloc returns the address of size free bytes.
+  (func (export "bongo_alloc") (type $unary)
+    global.get $heap
+    global.get $heap
+    local.get 0
+    i32.add
+    global.set $heap)
+
+  ;; bongo_markdown uppercases the ascii letters of the input, in place.
+  (func (export "bongo_markdown") (type $transform)
+    (local $i i32) (local $c i32)
+    block
+      loop
+        local.get $i
+        local.get 1
+        i32.ge_u
+        br_if 1
+        local.get 0
+        local.get $i
+        i32.add
+        i32.load8_u
+        local.set $c
+        local.get $c
+        i32.const 97 ;; a
+        i32.sub
+        i32.const 25
+        i32.le_u
+        if
+          local.get 0
+          local.get $i
+          i32.add
+          local.get $c
+          i32.const 32
+          i32.sub
+          i32.store8
+        end
+        local.get $i
+        i32.const 1
+        i32.add
+        local.set $i
+        br 0
+      end
+    end
+    local.get 0
+    i64.extend_i32_u
+    i64.const 32
+    i64.shl
+    local.get 1
+    i64.extend_i32_u
+    i64.or)
+
+  ;; bongo_html replaces o with 0 in the input, in place.
+  (func (export "bongo_html") (type $transform)
+    (local $i i32) (local $c i32)
+    block
+      loop
+        local.get $i
+        local.get 1
+        i32.ge_u
+        br_if 1
+        local.get 0
+        local.get $i
+        i32.add
+        i32.load8_u
+        local.set $c
+        local.get $c
+        i32.const 111 ;; o
+        i32.eq
+        if
+          local.get 0
+          local.get $i
+          i32.add
+          i32.const 48 ;; 0
+          i32.store8
+        end
+        local.get $i
+        i32.const 1
+        i32.add
+        local.set $i
+        br 0
+      end
+    end
+    local.get 0
+    i64.extend_i32_u
+    i64.const 32
+    i64.shl
+    local.get 1
+    i64.extend_i32_u
+    i64.or))
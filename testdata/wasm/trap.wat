@@ -0,0 +1,21 @@
+;; assembled with wat2wasm from WABT, without debug names:
+;;
+;;	wat2wasm trap.wat -o trap.wasm
+;;
+;; the bytes of the output are trapModule in wasm_test.go.
+(module
+  (type $unary (func (param i32) (result i32)))
+  (type $transform (func (param i32 i32) (result i64)))
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  (func (export "bongo_alloc") (type $unary)
+    global.get $heap
+    global.get $heap
+    local.get 0
+    i32.add
+    global.set $heap)
+
+  ;; bongo_html traps.
+  (func (export "bongo_html") (type $transform)
+    unreachable))
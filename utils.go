@@ -1,6 +1,12 @@
 package bongo
 
-import "path/filepath"
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
 
 //HasExt hecks if the file has any mathing extension
 func HasExt(file string, exts ...string) bool {
@@ -14,3 +20,22 @@ func HasExt(file string, exts ...string) bool {
 	}
 	return false
 }
+
+// projectFile returns the path of the file name, relative to the project root,
+// which must be in the project.
+func projectFile(root, name string) (string, error) {
+	clean := path.Clean("/" + name)[1:]
+	if name == "" || clean != strings.TrimPrefix(name, "./") {
+		return "", fmt.Errorf("page %q is outside the project", name)
+	}
+	return filepath.Join(root, filepath.FromSlash(clean)), nil
+}
+
+// absURL returns the url u of the site after baseURL. Urls with a scheme or a
+// host are returned as they are.
+func absURL(baseURL, u string) string {
+	if v, err := url.Parse(u); err == nil && (v.Scheme != "" || v.Host != "") {
+		return u
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(u, "/")
+}
@@ -0,0 +1,171 @@
+package bongo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const (
+	//DefaultWasmMemory is the memory limit of WebAssembly plugins in MiB, when
+	// none is configured.
+	DefaultWasmMemory = 16
+
+	// the functions exported by WebAssembly plugins, see wasmPlugin.
+	wasmAlloc    = "bongo_alloc"
+	wasmMarkdown = "bongo_markdown"
+	wasmHTML     = "bongo_html"
+
+	// wasmFailed is returned by the transform functions when they fail.
+	wasmFailed = ^uint64(0)
+
+	wasmPageSize = 64 * 1024
+)
+
+// wasmPlugin runs the content transforms of a WebAssembly module, see the
+// plugins setting in the package documentation for the ABI. The module has no
+// access to files or the network, its memory is limited, and it is
+// instantiated for every call, which must finish before the timeout.
+type wasmPlugin struct {
+	PluginBase
+
+	cfg            *PluginConfig
+	root           string
+	runtime        wazero.Runtime
+	module         wazero.CompiledModule
+	markdown, html bool
+}
+
+func newWasmPlugin(root string, c *PluginConfig) (*wasmPlugin, error) {
+	file := c.Module
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(root, file)
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	memory := c.Memory
+	if memory == 0 {
+		memory = DefaultWasmMemory
+	}
+	ctx := context.Background()
+	cfg := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(memory * 1024 * 1024 / wasmPageSize)).
+		WithCloseOnContextDone(true)
+	r := wazero.NewRuntimeWithConfig(ctx, cfg)
+	if _, err = wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		r.Close(ctx)
+		return nil, err
+	}
+	m, err := r.CompileModule(ctx, b)
+	if err != nil {
+		r.Close(ctx)
+		return nil, fmt.Errorf("%s: %v", c.Module, err)
+	}
+	p := &wasmPlugin{cfg: c, root: root, runtime: r, module: m}
+	fns := m.ExportedFunctions()
+	_, alloc := fns[wasmAlloc]
+	_, p.markdown = fns[wasmMarkdown]
+	_, p.html = fns[wasmHTML]
+	if !alloc || !(p.markdown || p.html) {
+		r.Close(ctx)
+		return nil, fmt.Errorf("%s: the module must export %s, and %s or %s", c.Module, wasmAlloc, wasmMarkdown, wasmHTML)
+	}
+	return p, nil
+}
+
+func (p *wasmPlugin) Name() string {
+	return p.cfg.Name
+}
+
+// OnPagesLoaded transforms the markdown of the pages.
+func (p *wasmPlugin) OnPagesLoaded(pages PageList) (PageList, error) {
+	if !p.markdown {
+		return pages, nil
+	}
+	for _, page := range pages {
+		b, err := p.transform(wasmMarkdown, page.Body)
+		if err != nil {
+			rel, _ := filepath.Rel(p.root, page.Path)
+			return nil, fmt.Errorf("%s: %v", filepath.ToSlash(rel), err)
+		}
+		page.Body = b
+	}
+	return pages, nil
+}
+
+// OnPostRender transforms the generated html files.
+func (p *wasmPlugin) OnPostRender(file string, b []byte) ([]byte, error) {
+	if !p.html || path.Ext(file) != DefaultExt {
+		return b, nil
+	}
+	out, err := p.transform(wasmHTML, b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return out, nil
+}
+
+//Close releases the runtime of the module.
+func (p *wasmPlugin) Close() error {
+	return p.runtime.Close(context.Background())
+}
+
+// transform calls the function fn of a new instance of the module with in, and
+// returns the output.
+func (p *wasmPlugin) transform(fn string, in []byte) ([]byte, error) {
+	timeout := p.cfg.Timeout
+	if timeout == 0 {
+		timeout = DefaultPluginTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cfg := wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize").WithStderr(&stderr)
+	failed := func(err error) error {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %v", timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	mod, err := p.runtime.InstantiateModule(ctx, p.module, cfg)
+	if err != nil {
+		return nil, failed(err)
+	}
+	defer mod.Close(context.Background())
+	mem := mod.Memory()
+	if mem == nil {
+		return nil, fmt.Errorf("the module exports no memory")
+	}
+	res, err := mod.ExportedFunction(wasmAlloc).Call(ctx, uint64(len(in)))
+	if err != nil {
+		return nil, failed(err)
+	}
+	ptr := uint32(res[0])
+	if !mem.Write(ptr, in) {
+		return nil, fmt.Errorf("%s returned %d, which is out of memory", wasmAlloc, ptr)
+	}
+	res, err = mod.ExportedFunction(fn).Call(ctx, uint64(ptr), uint64(len(in)))
+	if err != nil {
+		return nil, failed(err)
+	}
+	if res[0] == wasmFailed {
+		return nil, failed(fmt.Errorf("%s failed", fn))
+	}
+	out, ok := mem.Read(uint32(res[0]>>32), uint32(res[0]))
+	if !ok {
+		return nil, fmt.Errorf("%s returned output out of memory", fn)
+	}
+	return append([]byte(nil), out...), nil
+}
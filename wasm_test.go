@@ -0,0 +1,111 @@
+package bongo
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The test modules are assembled from the sources in testdata/wasm with
+// wat2wasm, see the comment at the top of the files.
+
+// transformModule uppercases markdown and replaces o with 0 in html, it is
+// testdata/wasm/transform.wat.
+var transformModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x0c, 0x02, 0x60,
+	0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e, 0x03, 0x04,
+	0x03, 0x00, 0x01, 0x01, 0x05, 0x03, 0x01, 0x00, 0x01, 0x06, 0x07, 0x01,
+	0x7f, 0x01, 0x41, 0x80, 0x08, 0x0b, 0x07, 0x36, 0x04, 0x06, 0x6d, 0x65,
+	0x6d, 0x6f, 0x72, 0x79, 0x02, 0x00, 0x0b, 0x62, 0x6f, 0x6e, 0x67, 0x6f,
+	0x5f, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x00, 0x00, 0x0e, 0x62, 0x6f, 0x6e,
+	0x67, 0x6f, 0x5f, 0x6d, 0x61, 0x72, 0x6b, 0x64, 0x6f, 0x77, 0x6e, 0x00,
+	0x01, 0x0a, 0x62, 0x6f, 0x6e, 0x67, 0x6f, 0x5f, 0x68, 0x74, 0x6d, 0x6c,
+	0x00, 0x02, 0x0a, 0x97, 0x01, 0x03, 0x0b, 0x00, 0x23, 0x00, 0x23, 0x00,
+	0x20, 0x00, 0x6a, 0x24, 0x00, 0x0b, 0x47, 0x01, 0x02, 0x7f, 0x02, 0x40,
+	0x03, 0x40, 0x20, 0x02, 0x20, 0x01, 0x4f, 0x0d, 0x01, 0x20, 0x00, 0x20,
+	0x02, 0x6a, 0x2d, 0x00, 0x00, 0x21, 0x03, 0x20, 0x03, 0x41, 0xe1, 0x00,
+	0x6b, 0x41, 0x19, 0x4d, 0x04, 0x40, 0x20, 0x00, 0x20, 0x02, 0x6a, 0x20,
+	0x03, 0x41, 0x20, 0x6b, 0x3a, 0x00, 0x00, 0x0b, 0x20, 0x02, 0x41, 0x01,
+	0x6a, 0x21, 0x02, 0x0c, 0x00, 0x0b, 0x0b, 0x20, 0x00, 0xad, 0x42, 0x20,
+	0x86, 0x20, 0x01, 0xad, 0x84, 0x0b, 0x41, 0x01, 0x02, 0x7f, 0x02, 0x40,
+	0x03, 0x40, 0x20, 0x02, 0x20, 0x01, 0x4f, 0x0d, 0x01, 0x20, 0x00, 0x20,
+	0x02, 0x6a, 0x2d, 0x00, 0x00, 0x21, 0x03, 0x20, 0x03, 0x41, 0xef, 0x00,
+	0x46, 0x04, 0x40, 0x20, 0x00, 0x20, 0x02, 0x6a, 0x41, 0x30, 0x3a, 0x00,
+	0x00, 0x0b, 0x20, 0x02, 0x41, 0x01, 0x6a, 0x21, 0x02, 0x0c, 0x00, 0x0b,
+	0x0b, 0x20, 0x00, 0xad, 0x42, 0x20, 0x86, 0x20, 0x01, 0xad, 0x84, 0x0b,
+}
+
+// loopModule never returns from bongo_markdown, it is testdata/wasm/loop.wat.
+var loopModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x0c, 0x02, 0x60,
+	0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e, 0x03, 0x03,
+	0x02, 0x00, 0x01, 0x05, 0x03, 0x01, 0x00, 0x01, 0x06, 0x07, 0x01, 0x7f,
+	0x01, 0x41, 0x80, 0x08, 0x0b, 0x07, 0x29, 0x03, 0x06, 0x6d, 0x65, 0x6d,
+	0x6f, 0x72, 0x79, 0x02, 0x00, 0x0b, 0x62, 0x6f, 0x6e, 0x67, 0x6f, 0x5f,
+	0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x00, 0x00, 0x0e, 0x62, 0x6f, 0x6e, 0x67,
+	0x6f, 0x5f, 0x6d, 0x61, 0x72, 0x6b, 0x64, 0x6f, 0x77, 0x6e, 0x00, 0x01,
+	0x0a, 0x17, 0x02, 0x0b, 0x00, 0x23, 0x00, 0x23, 0x00, 0x20, 0x00, 0x6a,
+	0x24, 0x00, 0x0b, 0x09, 0x00, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x42, 0x00,
+	0x0b,
+}
+
+// trapModule traps in bongo_html, it is testdata/wasm/trap.wat.
+var trapModule = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, 0x01, 0x0c, 0x02, 0x60,
+	0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e, 0x03, 0x03,
+	0x02, 0x00, 0x01, 0x05, 0x03, 0x01, 0x00, 0x01, 0x06, 0x07, 0x01, 0x7f,
+	0x01, 0x41, 0x80, 0x08, 0x0b, 0x07, 0x25, 0x03, 0x06, 0x6d, 0x65, 0x6d,
+	0x6f, 0x72, 0x79, 0x02, 0x00, 0x0b, 0x62, 0x6f, 0x6e, 0x67, 0x6f, 0x5f,
+	0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x00, 0x00, 0x0a, 0x62, 0x6f, 0x6e, 0x67,
+	0x6f, 0x5f, 0x68, 0x74, 0x6d, 0x6c, 0x00, 0x01, 0x0a, 0x11, 0x02, 0x0b,
+	0x00, 0x23, 0x00, 0x23, 0x00, 0x20, 0x00, 0x6a, 0x24, 0x00, 0x0b, 0x03,
+	0x00, 0x00, 0x0b,
+}
+
+func TestWasmPlugins(t *testing.T) {
+	files := map[string]string{
+		"hello.md":                "---\ntitle: Hello\nsection: blog\n---\nhello world",
+		"plugins/transform.wasm":  string(transformModule),
+		"plugins/loop.wasm":       string(loopModule),
+		"plugins/trap.wasm":       string(trapModule),
+		"_themes/plain/post.html": `{{.Page.HTML}} done`,
+	}
+	dir := writeProject(t, plainTheme(files))
+	defer os.RemoveAll(dir)
+	build := func(name string) error {
+		config := fmt.Sprintf(`
+theme: plain
+plugins:
+  - name: %s
+    module: plugins/%s.wasm
+    memory: 1
+    timeout: 1s
+`, name, name)
+		if err := ioutil.WriteFile(filepath.Join(dir, DefaultConfigFile), []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return New().Run(dir)
+	}
+
+	err := build("transform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, OutputDir, "blog", "hello.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := "<p>HELLO WORLD</p> d0ne"; strings.TrimSpace(string(b)) != v {
+		t.Errorf("expected %q got %q", v, b)
+	}
+
+	if err = build("loop"); err == nil || err.Error() != "plugin loop: OnPagesLoaded: hello.md: timed out after 1s" {
+		t.Errorf("expected a timeout got %v", err)
+	}
+	if err = build("trap"); err == nil || !strings.HasPrefix(err.Error(), "plugin trap: OnPostRender: blog/hello.html: ") {
+		t.Errorf("expected a trap got %v", err)
+	}
+}